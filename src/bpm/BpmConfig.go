@@ -0,0 +1,103 @@
+package main;
+
+import (
+    "os"
+    "path"
+    "io/ioutil"
+    "encoding/json"
+    "bpmerror"
+)
+
+/*
+The optional .bpmrc file can be placed in the home folder and in the project folder. Values in the project file
+take priority over the values in the home file and command line options take priority over both.
+{
+    "bump": "auto",
+    "logLevel": "info",
+    "logFormat": "text",
+    "cycles": "fail",
+    "installMode": "each",
+    "exclude": ["*.log", "test/fixtures/"],
+    "retries": "2",
+    "rewrite": {
+        "https://neudesic.timu.com/projects/": "https://git.internal/"
+    }
+}
+*/
+
+type BpmConfig struct {
+    Bump string `json:"bump,omitempty"`
+    LogLevel string `json:"logLevel,omitempty"`
+    LogFormat string `json:"logFormat,omitempty"`
+    Cycles string `json:"cycles,omitempty"`
+    InstallMode string `json:"installMode,omitempty"`
+    Exclude []string `json:"exclude,omitempty"`
+    Retries string `json:"retries,omitempty"`
+    // The url prefixes to replace and the prefixes that replace them
+    Rewrite map[string]string `json:"rewrite,omitempty"`
+}
+
+func (config *BpmConfig) Merge(other *BpmConfig) {
+    if other.Bump != "" {
+        config.Bump = other.Bump;
+    }
+    if other.LogLevel != "" {
+        config.LogLevel = other.LogLevel;
+    }
+    if other.LogFormat != "" {
+        config.LogFormat = other.LogFormat;
+    }
+    if other.Cycles != "" {
+        config.Cycles = other.Cycles;
+    }
+    if other.InstallMode != "" {
+        config.InstallMode = other.InstallMode;
+    }
+    if len(other.Exclude) > 0 {
+        config.Exclude = other.Exclude;
+    }
+    if other.Retries != "" {
+        config.Retries = other.Retries;
+    }
+    // The rules are merged so the project file only needs the rules that are different
+    for prefix, replacement := range other.Rewrite {
+        if config.Rewrite == nil {
+            config.Rewrite = make(map[string]string)
+        }
+        config.Rewrite[prefix] = replacement
+    }
+}
+
+func (config *BpmConfig) LoadFile(file string) error {
+    dat, err := ioutil.ReadFile(file)
+    if err != nil {
+        return err
+    }
+    jsondata := BpmConfig{};
+    err = json.Unmarshal(dat, &jsondata);
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.Usage, err, "Error: There was a problem reading the config file " + file)
+    }
+    config.Merge(&jsondata);
+    return nil;
+}
+
+// Load the config file from the home folder and then from the working folder. A missing config file is not an error.
+func LoadBpmConfig(workingDir string) (*BpmConfig, error) {
+    config := &BpmConfig{};
+    files := []string{}
+    if home := os.Getenv("HOME"); home != "" {
+        files = append(files, path.Join(home, Options.ConfigFileName))
+    }
+    files = append(files, path.Join(workingDir, Options.ConfigFileName))
+    for _, file := range files {
+        if !PathExists(file) {
+            continue;
+        }
+        err := config.LoadFile(file)
+        if err != nil {
+            return nil, err;
+        }
+    }
+    return config, nil;
+}
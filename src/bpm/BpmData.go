@@ -4,7 +4,6 @@ import (
     "io/ioutil"
     "encoding/json"
     "github.com/blang/semver"
-    "fmt"
     "strings"
     "bpmerror"
     "sort"
@@ -24,33 +23,64 @@ import (
             "commit": "abebc61f36b61e68d946392cf8457683ea20abc5",
             "url": "https://github.com/brandon-bethke-neudesic/bpmdep2.git"
         }
+    },
+    "scripts": {
+        "postinstall": "npm run generate"
+    },
+    "overrides": {
+        "mortar": {
+            "commit": "90b0a2da501451cf55ee07f9faeb3f8707af6011"
+        },
+        "login-client>mortar": {
+            "commit": "6c1f2e5a34d7a1c0f1b3a8c6e0b3a9a2d4e5f6a7",
+            "url": "https://github.com/brandon-bethke-neudesic/mortar-hotfix.git"
+        }
     }
 }
 */
 
+// The dependencies of every group are kept in the Dependencies map and the group of each dependency is in its Group
+// field. The groups are only separate in the file.
 type BpmData struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
     Dependencies map[string]*BpmDependency `json:"dependencies"`
+    DevDependencies map[string]*BpmDependency `json:"devDependencies,omitempty"`
+    OptionalDependencies map[string]*BpmDependency `json:"optionalDependencies,omitempty"`
+    Scripts map[string]string `json:"scripts,omitempty"`
+    Overrides map[string]*BpmDependency `json:"overrides,omitempty"`
 }
 
 func LoadBpmData(source string) (*BpmData, error) {
     bpm := &BpmData{};
     bpmJsonFile := path.Join(source, Options.BpmFileName);
-    err := bpm.LoadFile(bpmJsonFile);
+    err := bpm.LoadDependencyFile(bpmJsonFile);
     if err != nil {
-        return nil, bpmerror.New(err, "Error: Could not load the bpm.json file for dependency " + source)
+        return nil, bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: Could not load the bpm.json file for dependency " + source)
     }
     return bpm, nil;
 }
 
 
-func (bpm *BpmData) IncrementVersion() (error){
+// Increment the version by the specified level which is one of none, patch, minor or major
+func (bpm *BpmData) IncrementVersion(level string) (error){
+    if level == "none" {
+        return nil;
+    }
     bpmVersion, err := semver.Make(bpm.Version);
     if err != nil {
-        return bpmerror.New(nil, "Error: Could not read the version field from the bpm file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: Could not read the version field from the bpm file")
+    }
+    if level == "major" {
+        bpmVersion.Major++;
+        bpmVersion.Minor = 0;
+        bpmVersion.Patch = 0;
+    } else if level == "minor" {
+        bpmVersion.Minor++;
+        bpmVersion.Patch = 0;
+    } else {
+        bpmVersion.Patch++;
     }
-    bpmVersion.Patch++;
     bpm.Version = bpmVersion.String();
     return nil;
 }
@@ -65,9 +95,9 @@ func (bpm *BpmData) HasDependencies() bool {
 }
 
 func (bpm *BpmData) String() string {
-    bytes, err := json.MarshalIndent(bpm, "", "   ")
+    bytes, err := json.MarshalIndent(bpm.splitGroups(), "", "   ")
     if err != nil {
-        fmt.Println(err)
+        Log.Error(err)
         return "";
     }
 
@@ -85,8 +115,43 @@ func (bpm *BpmData) GetSortedKeys() []string {
     return sortedKeys;
 }
 
+// Returns a copy with the dependencies in the maps of their groups, the same as in the file
+func (bpm *BpmData) splitGroups() *BpmData {
+    split := *bpm
+    split.Dependencies = make(map[string]*BpmDependency)
+    split.DevDependencies = nil
+    split.OptionalDependencies = nil
+    for name, dep := range bpm.Dependencies {
+        if dep.IsDev() {
+            if split.DevDependencies == nil {
+                split.DevDependencies = make(map[string]*BpmDependency)
+            }
+            split.DevDependencies[name] = dep
+        } else if dep.IsOptional() {
+            if split.OptionalDependencies == nil {
+                split.OptionalDependencies = make(map[string]*BpmDependency)
+            }
+            split.OptionalDependencies[name] = dep
+        } else {
+            split.Dependencies[name] = dep
+        }
+    }
+    return &split;
+}
+
+// Returns the sorted names of the dependencies to install. The dev dependencies are only included when includeDev is true.
+func (bpm *BpmData) GetInstallKeys(includeDev bool) []string {
+    sortedKeys := []string{}
+    for _, name := range bpm.GetSortedKeys() {
+        if includeDev || !bpm.Dependencies[name].IsDev() {
+            sortedKeys = append(sortedKeys, name)
+        }
+    }
+    return sortedKeys;
+}
+
 func (bpm *BpmData) WriteFile(file string) error {
-    bytes, err := json.MarshalIndent(bpm, "", "   ")
+    bytes, err := json.MarshalIndent(bpm.splitGroups(), "", "   ")
     if err != nil {
         return err;
     }
@@ -100,11 +165,11 @@ func (bpm *BpmData) WriteFile(file string) error {
 
 func (bpm *BpmData) Validate() error {
     if strings.TrimSpace(bpm.Name) == "" {
-        return bpmerror.New(nil, "Error: There must be a name field in the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: There must be a name field in the bpm.json file")
     }
 
     if strings.TrimSpace(bpm.Version) == "" {
-        return bpmerror.New(nil, "Error: There must be a version field in the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: There must be a version field in the bpm.json file")
     }
     return nil;
 }
@@ -114,14 +179,66 @@ func (bpm *BpmData) LoadFile(file string) error {
     if err != nil {
         return err
     }
+    return bpm.LoadData(file, dat);
+}
+
+func (bpm *BpmData) Load(dat []byte) error {
+    return bpm.LoadData(Options.BpmFileName, dat);
+}
+
+// Validate and load the data. The file name is only used to report the location of the problems.
+func (bpm *BpmData) LoadData(file string, dat []byte) error {
+    return bpm.loadData(&BpmSchema{File: file}, dat);
+}
+
+// Load the bpm.json of a dependency. The file is at a commit that cannot be changed, so the unknown fields and the
+// invalid values are only reported as warnings.
+func (bpm *BpmData) LoadDependencyFile(file string) error {
+    dat, err := ioutil.ReadFile(file)
+    if err != nil {
+        return err
+    }
+    return bpm.LoadDependencyData(file, dat);
+}
+
+func (bpm *BpmData) LoadDependencyData(file string, dat []byte) error {
+    schema := &BpmSchema{File: file, Lenient: true}
+    err := bpm.loadData(schema, dat)
+    for _, warning := range schema.Warnings {
+        Log.Warn("Warning:", warning)
+    }
+    return err;
+}
+
+func (bpm *BpmData) loadData(schema *BpmSchema, dat []byte) error {
+    err := schema.Validate(dat)
+    if err != nil {
+        return err;
+    }
     jsondata := BpmData{};
     err = json.Unmarshal(dat, &jsondata);
     if err != nil {
         return err
     }
     bpm.Dependencies = jsondata.Dependencies;
+    if bpm.Dependencies == nil {
+        bpm.Dependencies = make(map[string]*BpmDependency)
+    }
+    for _, dep := range bpm.Dependencies {
+        dep.Group = DependencyGroup
+    }
+    for name, dep := range jsondata.DevDependencies {
+        dep.Group = DevDependencyGroup
+        bpm.Dependencies[name] = dep
+    }
+    for name, dep := range jsondata.OptionalDependencies {
+        dep.Group = OptionalDependencyGroup
+        bpm.Dependencies[name] = dep
+    }
     bpm.Name = jsondata.Name
     bpm.Version = jsondata.Version
+    bpm.Scripts = jsondata.Scripts
+    bpm.Overrides = jsondata.Overrides
     return nil;
 }
 
@@ -129,6 +246,8 @@ func (bpm *BpmData) Clone(includeModules string) *BpmData {
     newBpm := &BpmData{};
     newBpm.Name = bpm.Name;
     newBpm.Version = bpm.Version;
+    newBpm.Scripts = bpm.Scripts;
+    newBpm.Overrides = bpm.Overrides;
     newBpm.Dependencies = make(map[string]*BpmDependency);
     for name, v := range bpm.Dependencies {
         newBpm.Dependencies[name] = v
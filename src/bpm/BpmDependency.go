@@ -8,14 +8,46 @@ import (
 type BpmDependency struct {
     Commit string `json:"commit"`
     Url    string `json:"url"`
+    Branch string `json:"branch,omitempty"`
+    // The modules that are not installed through this dependency, at any level below it
+    Exclude []string `json:"exclude,omitempty"`
+    // The urls that are tried in order when the url cannot be fetched
+    Mirrors []string `json:"mirrors,omitempty"`
+    // The group the dependency is in, one of dependencies, devDependencies or optionalDependencies
+    Group string `json:"-"`
+}
+
+const (
+    DependencyGroup = "dependencies"
+    DevDependencyGroup = "devDependencies"
+    OptionalDependencyGroup = "optionalDependencies"
+)
+
+// Dev dependencies are only installed for the root project
+func (dep *BpmDependency) IsDev() bool {
+    return dep.Group == DevDependencyGroup;
+}
+
+// A failure to fetch an optional dependency does not fail the install
+func (dep *BpmDependency) IsOptional() bool {
+    return dep.Group == OptionalDependencyGroup;
+}
+
+// Returns the branch to follow when updating this dependency. The --branch option takes priority over the branch
+// in the bpm.json. An empty result means the default branch of the remote should be used.
+func (dep *BpmDependency) GetBranch() string {
+    if Options.UseBranch != "" {
+        return Options.UseBranch;
+    }
+    return dep.Branch;
 }
 
 func (dep *BpmDependency) Validate() (error) {
     if strings.TrimSpace(dep.Url) == "" {
-        return bpmerror.New(nil, "Error: No url specified")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: No url specified")
     }
     if strings.TrimSpace(dep.Commit) == "" {
-        return bpmerror.New(nil, "Error: No commit specified")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: No commit specified")
     }
     return nil
 }
@@ -25,7 +57,7 @@ func (dep *BpmDependency) Equal(item *BpmDependency) bool {
     if dep == item {
         return true;
     }
-    if item.Commit == dep.Commit && item.Url == dep.Url {
+    if item.Commit == dep.Commit && item.Url == dep.Url && item.Branch == dep.Branch && item.Group == dep.Group {
         return true;
     }
     return false;
@@ -0,0 +1,126 @@
+package main;
+
+import (
+    "path"
+    "strings"
+    "io/ioutil"
+    "bpmerror"
+)
+
+// A pattern from the exclude list or from a .bpmignore file. The patterns use the gitignore syntax.
+type IgnorePattern struct {
+    Pattern string
+    Negate bool
+    DirOnly bool
+    Anchored bool
+}
+
+// The files that are not copied. The last pattern that matches a path decides if the path is ignored, so a pattern
+// that starts with ! can include a path again.
+type IgnoreList struct {
+    Patterns []IgnorePattern
+}
+
+// Create the list from the pipe separated exclude list, for example .git|node_modules|*.log
+func NewIgnoreList(exclude string) *IgnoreList {
+    list := &IgnoreList{}
+    if exclude != "" {
+        for _, pattern := range strings.Split(exclude, "|") {
+            list.Add(pattern)
+        }
+    }
+    return list;
+}
+
+func (list *IgnoreList) Add(line string) {
+    line = strings.TrimRight(line, " \t\r")
+    if line == "" || strings.HasPrefix(line, "#") {
+        return;
+    }
+    pattern := IgnorePattern{}
+    if strings.HasPrefix(line, "!") {
+        pattern.Negate = true
+        line = line[1:]
+    } else if strings.HasPrefix(line, "\\") {
+        line = line[1:]
+    }
+    if strings.HasSuffix(line, "/") {
+        pattern.DirOnly = true
+        line = strings.TrimRight(line, "/")
+    }
+    // A pattern with a slash at the start or in the middle is relative to the root. Otherwise it matches at any level.
+    if strings.Contains(line, "/") {
+        pattern.Anchored = true
+        line = strings.TrimPrefix(line, "/")
+    }
+    if line == "" {
+        return;
+    }
+    pattern.Pattern = line
+    list.Patterns = append(list.Patterns, pattern)
+}
+
+// Add the patterns in the ignore file. A missing file is not an error.
+func (list *IgnoreList) LoadFile(file string) error {
+    if !PathExists(file) {
+        return nil;
+    }
+    dat, err := ioutil.ReadFile(file)
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem reading the file " + file)
+    }
+    for _, line := range strings.Split(string(dat), "\n") {
+        list.Add(line)
+    }
+    return nil;
+}
+
+// Returns a copy of the list with the patterns of the ignore file in the folder added
+func (list *IgnoreList) WithFile(folder string, fileName string) (*IgnoreList, error) {
+    result := &IgnoreList{Patterns: append([]IgnorePattern{}, list.Patterns...)}
+    if fileName == "" {
+        return result, nil;
+    }
+    err := result.LoadFile(path.Join(folder, fileName))
+    return result, err;
+}
+
+// Returns true if the path, relative to the root of the copy, is ignored
+func (list *IgnoreList) Match(relativePath string, isDir bool) bool {
+    ignored := false
+    for _, pattern := range list.Patterns {
+        if pattern.DirOnly && !isDir {
+            continue;
+        }
+        var matched bool
+        if pattern.Anchored {
+            matched = matchGlob(strings.Split(pattern.Pattern, "/"), strings.Split(relativePath, "/"))
+        } else {
+            matched, _ = path.Match(pattern.Pattern, path.Base(relativePath))
+        }
+        if matched {
+            ignored = !pattern.Negate
+        }
+    }
+    return ignored;
+}
+
+// Match the path segments against the pattern segments where ** matches any number of segments
+func matchGlob(pattern []string, segments []string) bool {
+    if len(pattern) == 0 {
+        return len(segments) == 0;
+    }
+    if pattern[0] == "**" {
+        for i := 0; i <= len(segments); i++ {
+            if matchGlob(pattern[1:], segments[i:]) {
+                return true;
+            }
+        }
+        return false;
+    }
+    if len(segments) == 0 {
+        return false;
+    }
+    matched, _ := path.Match(pattern[0], segments[0])
+    return matched && matchGlob(pattern[1:], segments[1:]);
+}
@@ -0,0 +1,79 @@
+package main;
+
+import (
+    "os"
+    "testing"
+    "path/filepath"
+)
+
+func TestIgnoreListMatch(t *testing.T) {
+    tests := []struct {
+        name string
+        exclude string
+        path string
+        isDir bool
+        expected bool
+    }{
+        {name: "name", exclude: ".git|node_modules", path: ".git", isDir: true, expected: true},
+        {name: "name at any level", exclude: "node_modules", path: "lib/node_modules", isDir: true, expected: true},
+        {name: "other name", exclude: ".git|node_modules", path: "src", isDir: true, expected: false},
+        {name: "glob", exclude: "*.log", path: "logs/debug.log", expected: true},
+        {name: "glob does not match", exclude: "*.log", path: "debug.log.txt", expected: false},
+        {name: "folder only", exclude: "docs/", path: "docs", isDir: true, expected: true},
+        {name: "folder only skips a file", exclude: "docs/", path: "docs", expected: false},
+        {name: "anchored", exclude: "/build", path: "build", isDir: true, expected: true},
+        {name: "anchored below the root", exclude: "/build", path: "lib/build", isDir: true, expected: false},
+        {name: "path", exclude: "lib/*.map", path: "lib/index.map", expected: true},
+        {name: "path at another level", exclude: "lib/*.map", path: "src/lib/index.map", expected: false},
+        {name: "double star", exclude: "**/test/*.js", path: "lib/a/test/unit.js", expected: true},
+        {name: "double star at the root", exclude: "**/test/*.js", path: "test/unit.js", expected: true},
+        {name: "double star in the middle", exclude: "lib/**/*.md", path: "lib/a/b/README.md", expected: true},
+        {name: "negate", exclude: "*.log|!keep.log", path: "keep.log", expected: false},
+        {name: "last pattern wins", exclude: "!keep.log|*.log", path: "keep.log", expected: true},
+        {name: "escaped", exclude: "\\!important", path: "!important", expected: true},
+        {name: "comment", exclude: "#notes", path: "#notes", expected: false},
+        {name: "empty", exclude: "", path: "anything", expected: false},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            list := NewIgnoreList(test.exclude)
+            if list.Match(test.path, test.isDir) != test.expected {
+                t.Errorf("Expected %q to match %s to be %v", test.exclude, test.path, test.expected)
+            }
+        })
+    }
+}
+
+func TestIgnoreListWithFile(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    writeFiles(t, temp, map[string]string{".bpmignore": "# Documentation\ndocs/\r\n*.md\n!README.md\n\n"})
+    list := NewIgnoreList(".git")
+    withFile, err := list.WithFile(temp, ".bpmignore")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        path string
+        isDir bool
+        expected bool
+    }{
+        {path: ".git", isDir: true, expected: true},
+        {path: "docs", isDir: true, expected: true},
+        {path: "guide.md", expected: true},
+        {path: "README.md", expected: false},
+        {path: "index.js", expected: false},
+    }
+    for _, test := range tests {
+        if withFile.Match(test.path, test.isDir) != test.expected {
+            t.Errorf("Expected the match of %s to be %v", test.path, test.expected)
+        }
+    }
+    if len(list.Patterns) != 1 {
+        t.Errorf("Expected the list to be unchanged but got %v", list.Patterns)
+    }
+    missing, err := list.WithFile(filepath.Join(temp, "missing"), ".bpmignore")
+    if err != nil || len(missing.Patterns) != 1 {
+        t.Errorf("Expected a missing ignore file to be ignored but got %v %v", missing.Patterns, err)
+    }
+}
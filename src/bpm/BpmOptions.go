@@ -1,11 +1,10 @@
 package main;
 
 import (
+    "strconv"
     "strings"
     "path"
-    "fmt"
     "os"
-    "errors"
     "bpmerror"
 )
 
@@ -14,18 +13,45 @@ type BpmOptions struct {
     Recursive bool
     ConflictResolutionType string
     UseLocalPath string
+    Link bool
+    Checksum bool
+    FromVendor string
+    WatchExec string
+    WatchInterval string
+    WatchPoll bool
+    LsDepth string
+    Production bool
+    Retries string
     UseRemoteName string
     UseRemoteUrl string
+    UseBranch string
     BpmCachePath string
     BpmFileName string
     LocalModuleName string
+    InstallModuleName string
     ExcludeFileList string
     SkipNpmInstall bool
+    IgnoreScripts bool
     Finalize bool
     PackageManager string
+    InstallMode string
     WorkingDir string
     Trim bool
+    DryRun bool
+    KeepLocal bool
+    Yes bool
+    Commit bool
+    Tag bool
     UseParentUrl bool
+    Bump string
+    LogLevel string
+    LogFormat string
+    Cycles string
+    ConfigFileName string
+    InstalledFileName string
+    IgnoreFileName string
+    Config *BpmConfig
+    ConfigError error
     Command SubCommand
 }
 
@@ -37,7 +63,7 @@ func (options *BpmOptions) EnsureBpmCacheFolder() {
 
 func (options *BpmOptions) DoesBpmFileExist() (error) {
     if _, err := os.Stat(Options.BpmFileName); os.IsNotExist(err) {
-        return errors.New("Error: The " + Options.BpmFileName + " file does not exist.");
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: The " + Options.BpmFileName + " file does not exist.");
     }
     return nil
 }
@@ -69,33 +95,153 @@ func (options *BpmOptions) getSubCommand(args []string) (SubCommand){
         if command == "uninstall" {
             return &UninstallCommand{}
         }
-        fmt.Println("Unrecognized command", command)
+        if command == "doctor" {
+            return &DoctorCommand{}
+        }
+        if command == "prune" {
+            return &PruneCommand{}
+        }
+        if command == "watch" {
+            return &WatchCommand{}
+        }
+        if command == "vendor" {
+            return &VendorCommand{}
+        }
+        if command == "release" {
+            return &ReleaseCommand{}
+        }
+        if command == "rewrite-urls" {
+            return &RewriteUrlsCommand{}
+        }
+        Log.Warn("Unrecognized command", command)
     }
     return &HelpCommand{};
 }
 
 
 func (options *BpmOptions) Parse(args []string) {
+    options.WorkingDir, _ = os.Getwd();
+    options.Config, options.ConfigError = LoadBpmConfig(options.WorkingDir)
+    if options.Config == nil {
+        options.Config = &BpmConfig{}
+    }
+    // The exclude patterns from the config file and the command line are added to the default exclude list
+    exclude := append([]string{}, options.Config.Exclude...)
+    if value := options.GetNameValueOption(args, "--exclude=", ""); value != "" {
+        exclude = append(exclude, value)
+    }
+    for _, pattern := range exclude {
+        options.ExcludeFileList = options.ExcludeFileList + "|" + pattern
+    }
+    options.LogFormat = options.GetNameValueOption(args, "--log-format=", options.GetConfigValue(options.Config.LogFormat, "text"))
+    options.LogLevel = options.GetNameValueOption(args, "--log-level=", options.GetConfigValue(options.Config.LogLevel, "info"))
+    if options.GetBoolOption(args, "--quiet") {
+        options.LogLevel = "warn"
+    }
+    if options.GetBoolOption(args, "--verbose") {
+        options.LogLevel = "debug"
+    }
+    Log.Format = options.LogFormat
+    Log.Level, _ = ParseLogLevel(options.LogLevel)
     options.Command = options.getSubCommand(args)
     options.SkipNpmInstall = options.GetBoolOption(args, "--skipnpm")
+    options.IgnoreScripts = options.GetBoolOption(args, "--ignore-scripts")
     options.Recursive = options.GetBoolOption(args, "--recursive")
     options.ConflictResolutionType = options.GetNameValueOption(args, "--resolution=", "versioning")
     options.UseRemoteName = options.GetNameValueOption(args, "--remote=", "origin")
     options.UseRemoteUrl = options.GetNameValueOption(args, "--remoteurl=", "")
+    options.UseBranch = options.GetNameValueOption(args, "--branch=", "")
     options.UseLocalPath = options.GetRootOption(args);
+    options.Link = options.GetBoolOption(args, "--link")
+    options.Checksum = options.GetBoolOption(args, "--checksum")
+    options.FromVendor = options.GetNameValueOption(args, "--from-vendor=", "")
+    options.WatchExec = options.GetNameValueOption(args, "--exec=", "")
+    options.WatchInterval = options.GetNameValueOption(args, "--interval=", "1s")
+    options.WatchPoll = options.GetBoolOption(args, "--poll")
+    options.LsDepth = options.GetNameValueOption(args, "--depth=", "")
+    options.Production = options.GetBoolOption(args, "--production")
+    options.Retries = options.GetNameValueOption(args, "--retries=", options.GetConfigValue(options.Config.Retries, "2"))
     options.Finalize = options.GetBoolOption(args, "--finalize")
     options.PackageManager = options.GetNameValueOption(args, "--pkgm=", "npm")
-    options.WorkingDir, _ = os.Getwd();
+    options.InstallMode = options.GetNameValueOption(args, "--install-mode=", options.GetConfigValue(options.Config.InstallMode, "each"))
     options.Trim = options.GetBoolOption(args, "--trim")
+    options.DryRun = options.GetBoolOption(args, "--dry-run")
+    options.KeepLocal = options.GetBoolOption(args, "--keep-local")
+    options.Yes = options.GetBoolOption(args, "--yes")
+    options.Commit = options.GetBoolOption(args, "--commit")
+    options.Tag = options.GetBoolOption(args, "--tag")
     options.UseParentUrl = options.GetBoolOption(args, "--useparenturl")
+    options.Bump = options.GetNameValueOption(args, "--bump=", options.GetConfigValue(options.Config.Bump, "patch"))
+    options.Cycles = options.GetNameValueOption(args, "--cycles=", options.GetConfigValue(options.Config.Cycles, "fail"))
+}
+
+func (options *BpmOptions) GetConfigValue(configValue string, defaultValue string) string {
+    if configValue != "" {
+        return configValue;
+    }
+    return defaultValue;
 }
 
 func (options *BpmOptions) Validate() error {
+    if options.ConfigError != nil {
+        return options.ConfigError;
+    }
+    if _, valid := ParseLogLevel(options.LogLevel); !valid {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --log-level option must be one of error, warn, info, debug or trace")
+    }
+    if options.LogFormat != "text" && options.LogFormat != "json" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --log-format option must be one of text or json")
+    }
+    if options.Bump != "none" && options.Bump != "patch" && options.Bump != "minor" && options.Bump != "major" && options.Bump != "auto" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --bump option must be one of none, patch, minor, major or auto")
+    }
+    if options.Cycles != "fail" && options.Cycles != "break" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --cycles option must be one of fail or break")
+    }
+    if retries, err := strconv.Atoi(options.Retries); err != nil || retries < 0 {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --retries option must be a number of 0 or more")
+    }
+    if options.InstallMode != "each" && options.InstallMode != "package-json" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --install-mode option must be one of each or package-json")
+    }
     if options.Recursive && options.UseLocalPath == "" {
-        return bpmerror.New(nil, "Error: The --recursive option can only be used with the --root= option")
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --recursive option can only be used with the --root= option")
+    }
+    if options.Link && options.UseLocalPath == "" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --link option can only be used with the --root= option")
+    }
+    if options.Command.Name() == "watch" && options.UseLocalPath == "" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The watch command requires the --root= option")
+    }
+    if options.Command.Name() == "watch" && options.Link {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The watch command copies the local modules and cannot be used with the --link option")
+    }
+    if options.FromVendor != "" && options.Command.Name() != "install" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --from-vendor option can only be used with the install command")
+    }
+    if options.FromVendor != "" && options.UseLocalPath != "" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --from-vendor option cannot be used with the --root= option")
+    }
+    if options.LsDepth != "" && options.Command.Name() != "ls" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --depth= option can only be used with the ls command")
+    }
+    if options.Production && options.Command.Name() != "install" && options.Command.Name() != "ls" && options.Command.Name() != "vendor" && options.Command.Name() != "prune" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --production option can only be used with the install, ls, vendor and prune commands")
     }
     if options.Trim && options.Command.Name() != "clean" {
-        return bpmerror.New(nil, "Error: The --trim option can only be used with the clean command")
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --trim option can only be used with the clean command")
+    }
+    if options.DryRun && options.Command.Name() != "clean" && options.Command.Name() != "prune" && options.Command.Name() != "release" && options.Command.Name() != "rewrite-urls" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --dry-run option can only be used with the clean, prune, release and rewrite-urls commands")
+    }
+    if options.Commit && options.Command.Name() != "rewrite-urls" && !(options.Command.Name() == "update" && options.Recursive) {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --commit option can only be used with the rewrite-urls command and the update --recursive command")
+    }
+    if options.Tag && (!options.Commit || options.Command.Name() != "update") {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --tag option can only be used with the update --recursive --commit command")
+    }
+    if options.KeepLocal && !options.Trim {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --keep-local option can only be used with the clean --trim command")
     }
     return nil
 }
@@ -106,7 +252,8 @@ func (options *BpmOptions) GetNameValueOption(args []string, name string, defaul
     if index == -1 {
         return flagValue;
     }
-    temp := strings.Split(args[index], "=")
+    // Only split on the first = so the value can contain = characters
+    temp := strings.SplitN(args[index], "=", 2)
     if len(temp) == 2 {
         flagValue = temp[1];
     }
@@ -0,0 +1,395 @@
+package main;
+
+import (
+    "io"
+    "fmt"
+    "bytes"
+    "regexp"
+    "strings"
+    "net/url"
+    "encoding/json"
+    "github.com/blang/semver"
+    "bpmerror"
+)
+
+var bpmFields = []string{"name", "version", "dependencies", "devDependencies", "optionalDependencies", "scripts", "overrides"}
+var bpmDependencyFields = []string{"commit", "url", "branch", "exclude", "mirrors"}
+var bpmScriptNames = []string{"preinstall", "postinstall", "preupdate", "postupdate"}
+var commitPattern = regexp.MustCompile("^[0-9a-f]{7,40}$")
+
+// Validates the contents of a bpm.json file against the expected structure. Every problem is reported with the file,
+// line and column so it can be found and corrected. Unlike json.Unmarshal, unknown fields and duplicate keys are reported.
+type BpmSchema struct {
+    File string
+    Problems []string
+    // The bpm.json of a dependency at a commit cannot be corrected, so the unknown fields and the invalid values are only
+    // reported as warnings. Only the problems that prevent the file from loading are errors.
+    Lenient bool
+    Warnings []string
+    // The group of each dependency. A dependency can only be in one group.
+    groups map[string]string
+    data []byte
+    decoder *json.Decoder
+}
+
+func (schema *BpmSchema) position(offset int) (int, int) {
+    if offset > len(schema.data) {
+        offset = len(schema.data)
+    }
+    line := bytes.Count(schema.data[:offset], []byte("\n")) + 1
+    column := offset - bytes.LastIndex(schema.data[:offset], []byte("\n"))
+    return line, column;
+}
+
+func (schema *BpmSchema) problem(offset int, message string) {
+    line, column := schema.position(offset)
+    schema.Problems = append(schema.Problems, fmt.Sprintf("%s:%d:%d: %s", schema.File, line, column, message))
+}
+
+func (schema *BpmSchema) warning(offset int, message string) {
+    line, column := schema.position(offset)
+    schema.Warnings = append(schema.Warnings, fmt.Sprintf("%s:%d:%d: %s", schema.File, line, column, message))
+}
+
+// Report a problem that does not prevent the file from loading. It is only a warning when the schema is lenient.
+func (schema *BpmSchema) invalid(offset int, message string) {
+    if schema.Lenient {
+        schema.warning(offset, message)
+    } else {
+        schema.problem(offset, message)
+    }
+}
+
+// Suggest the closest known name for a misspelled name
+func (schema *BpmSchema) suggest(name string, known []string) string {
+    best := ""
+    bestDistance := len(name) / 2 + 1
+    for _, candidate := range known {
+        distance := editDistance(strings.ToLower(name), candidate)
+        if distance < bestDistance {
+            best = candidate
+            bestDistance = distance
+        }
+    }
+    if best == "" {
+        return "";
+    }
+    return ". Did you mean \"" + best + "\"?";
+}
+
+func editDistance(a string, b string) int {
+    previous := make([]int, len(b) + 1)
+    current := make([]int, len(b) + 1)
+    for j := range previous {
+        previous[j] = j
+    }
+    for i := 1; i <= len(a); i++ {
+        current[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i - 1] == b[j - 1] {
+                cost = 0
+            }
+            current[j] = minInt(minInt(previous[j] + 1, current[j - 1] + 1), previous[j - 1] + cost)
+        }
+        previous, current = current, previous
+    }
+    return previous[len(b)];
+}
+
+func minInt(a int, b int) int {
+    if a < b {
+        return a;
+    }
+    return b;
+}
+
+// Read the next token along with the offset where the token starts
+func (schema *BpmSchema) next() (json.Token, int, error) {
+    offset := int(schema.decoder.InputOffset())
+    for offset < len(schema.data) && strings.IndexByte(" \t\r\n,:", schema.data[offset]) != -1 {
+        offset++
+    }
+    token, err := schema.decoder.Token()
+    return token, offset, err;
+}
+
+// Skip the rest of a value when the first token was already read
+func (schema *BpmSchema) skip(token json.Token) error {
+    delim, ok := token.(json.Delim)
+    if !ok || delim == '}' || delim == ']' {
+        return nil;
+    }
+    depth := 1
+    for depth > 0 {
+        token, _, err := schema.next()
+        if err != nil {
+            return err;
+        }
+        if delim, ok := token.(json.Delim); ok {
+            if delim == '{' || delim == '[' {
+                depth++
+            } else {
+                depth--
+            }
+        }
+    }
+    return nil;
+}
+
+func (schema *BpmSchema) readObject(description string, field func(key string, offset int) error) error {
+    token, offset, err := schema.next()
+    if err != nil {
+        return err;
+    }
+    if token != json.Delim('{') {
+        schema.problem(offset, description + " must be an object")
+        return schema.skip(token);
+    }
+    seen := make(map[string]bool)
+    for {
+        token, offset, err = schema.next()
+        if err != nil {
+            return err;
+        }
+        if token == json.Delim('}') {
+            return nil;
+        }
+        key := token.(string)
+        if seen[key] {
+            schema.invalid(offset, "duplicate key \"" + key + "\" in " + description)
+        }
+        seen[key] = true
+        err = field(key, offset)
+        if err != nil {
+            return err;
+        }
+    }
+}
+
+func (schema *BpmSchema) readString(description string) (string, int, bool, error) {
+    token, offset, err := schema.next()
+    if err != nil {
+        return "", offset, false, err;
+    }
+    value, ok := token.(string)
+    if !ok {
+        schema.problem(offset, description + " must be a string")
+        return "", offset, false, schema.skip(token);
+    }
+    return value, offset, true, nil;
+}
+
+// Read a list of strings. Each string is passed to the validate function along with its offset.
+func (schema *BpmSchema) readStringList(description string, validate func(value string, offset int)) error {
+    token, offset, err := schema.next()
+    if err != nil {
+        return err;
+    }
+    if token != json.Delim('[') {
+        schema.problem(offset, description + " must be a list of strings")
+        return schema.skip(token);
+    }
+    for {
+        token, offset, err = schema.next()
+        if err != nil {
+            return err;
+        }
+        if token == json.Delim(']') {
+            return nil;
+        }
+        value, ok := token.(string)
+        if !ok || strings.TrimSpace(value) == "" {
+            // A value that is not a string cannot be loaded, an empty string can
+            if ok {
+                schema.invalid(offset, description + " must only contain non empty strings")
+            } else {
+                schema.problem(offset, description + " must only contain non empty strings")
+            }
+            err = schema.skip(token)
+            if err != nil {
+                return err;
+            }
+            continue
+        }
+        validate(value, offset)
+    }
+}
+
+func (schema *BpmSchema) unknownField(key string, offset int, description string, known []string) error {
+    schema.invalid(offset, "unknown field \"" + key + "\" in " + description + schema.suggest(key, known))
+    token, _, err := schema.next()
+    if err != nil {
+        return err;
+    }
+    return schema.skip(token);
+}
+
+func ValidateCommit(commit string) string {
+    if commit == Options.LocalModuleName || commitPattern.MatchString(commit) {
+        return "";
+    }
+    return "the commit \"" + commit + "\" is not a commit hash"
+}
+
+// Urls must be full http urls or relative urls
+func ValidateUrl(itemUrl string) string {
+    if strings.TrimSpace(itemUrl) == "" {
+        return "the url is empty";
+    }
+    if strings.ContainsAny(itemUrl, " \t\r\n") {
+        return "the url \"" + itemUrl + "\" contains whitespace";
+    }
+    if strings.Index(itemUrl, "http") == 0 {
+        parsedUrl, err := url.Parse(itemUrl)
+        if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+            return "the url \"" + itemUrl + "\" is not a valid http url";
+        }
+        return "";
+    }
+    if strings.Contains(itemUrl, "://") {
+        return "the url \"" + itemUrl + "\" must be an http url or a relative url";
+    }
+    return "";
+}
+
+func (schema *BpmSchema) readDependency(name string) error {
+    _, err := schema.readDependencyFields("dependency " + name)
+    return err;
+}
+
+// An override has the same fields as a dependency. The commit is required and the url is optional.
+func (schema *BpmSchema) readOverride(key string, offset int) error {
+    description := "override " + key
+    for _, name := range strings.Split(key, ">") {
+        if strings.TrimSpace(name) == "" {
+            schema.invalid(offset, "the " + description + " must be a module name or module names separated by >")
+            break
+        }
+    }
+    fields, err := schema.readDependencyFields(description)
+    if err == nil && !fields["commit"] {
+        schema.invalid(offset, "no commit specified in " + description)
+    }
+    return err;
+}
+
+// Read the fields of a dependency and return the names of the fields that were found
+func (schema *BpmSchema) readDependencyFields(description string) (map[string]bool, error) {
+    fields := make(map[string]bool)
+    err := schema.readObject(description, func(key string, offset int) error {
+        if !schema.contains(bpmDependencyFields, key) {
+            return schema.unknownField(key, offset, description, bpmDependencyFields)
+        }
+        if key == "exclude" {
+            fields[key] = true
+            return schema.readStringList("the exclude list of " + description, func(value string, offset int) {})
+        }
+        if key == "mirrors" {
+            fields[key] = true
+            return schema.readStringList("the mirrors of " + description, func(value string, offset int) {
+                if problem := ValidateUrl(value); problem != "" {
+                    schema.invalid(offset, problem + " in the mirrors of " + description)
+                }
+            })
+        }
+        value, valueOffset, ok, err := schema.readString("the " + key + " of " + description)
+        if err != nil || !ok {
+            return err;
+        }
+        fields[key] = true
+        if key == "commit" {
+            if problem := ValidateCommit(value); problem != "" {
+                schema.invalid(valueOffset, problem + " in " + description)
+            }
+        } else if key == "url" {
+            if problem := ValidateUrl(value); problem != "" {
+                schema.invalid(valueOffset, problem + " in " + description)
+            }
+        }
+        return nil;
+    })
+    return fields, err;
+}
+
+func (schema *BpmSchema) readRoot() error {
+    return schema.readObject("the bpm file", func(key string, offset int) error {
+        switch key {
+        case "name":
+            _, _, _, err := schema.readString("the name")
+            return err;
+        case "version":
+            value, valueOffset, ok, err := schema.readString("the version")
+            if err == nil && ok {
+                if _, versionErr := semver.Make(value); versionErr != nil {
+                    schema.invalid(valueOffset, "the version \"" + value + "\" is not a semantic version. " + versionErr.Error())
+                }
+            }
+            return err;
+        case DependencyGroup, DevDependencyGroup, OptionalDependencyGroup:
+            return schema.readObject("the " + key, func(name string, offset int) error {
+                if group, exists := schema.groups[name]; exists && group != key {
+                    schema.invalid(offset, "the dependency " + name + " is in both " + group + " and " + key)
+                }
+                schema.groups[name] = key
+                return schema.readDependency(name)
+            })
+        case "overrides":
+            return schema.readObject("the overrides", func(key string, offset int) error {
+                return schema.readOverride(key, offset)
+            })
+        case "scripts":
+            return schema.readObject("the scripts", func(name string, offset int) error {
+                if !schema.contains(bpmScriptNames, name) {
+                    return schema.unknownField(name, offset, "the scripts", bpmScriptNames)
+                }
+                _, _, _, err := schema.readString("the " + name + " script")
+                return err;
+            })
+        }
+        return schema.unknownField(key, offset, "the bpm file", bpmFields)
+    })
+}
+
+func (schema *BpmSchema) contains(list []string, value string) bool {
+    for _, item := range list {
+        if item == value {
+            return true;
+        }
+    }
+    return false;
+}
+
+func (schema *BpmSchema) syntaxProblem(err error) {
+    if syntaxError, ok := err.(*json.SyntaxError); ok {
+        // The offset is after the character that caused the error
+        schema.problem(int(syntaxError.Offset) - 1, syntaxError.Error())
+    } else if err == io.EOF || err == io.ErrUnexpectedEOF {
+        schema.problem(len(schema.data), "unexpected end of the file")
+    } else {
+        schema.problem(int(schema.decoder.InputOffset()), err.Error())
+    }
+}
+
+// Validate the data. All the problems found are returned in one error.
+func (schema *BpmSchema) Validate(data []byte) error {
+    schema.data = data
+    schema.Problems = []string{}
+    schema.Warnings = []string{}
+    schema.groups = make(map[string]string)
+    schema.decoder = json.NewDecoder(bytes.NewReader(data))
+    err := schema.readRoot()
+    if err != nil {
+        schema.syntaxProblem(err)
+    } else if token, offset, err := schema.next(); err != io.EOF {
+        if err != nil {
+            schema.syntaxProblem(err)
+        } else {
+            schema.problem(offset, fmt.Sprintf("unexpected %v after the end of the bpm file", token))
+        }
+    }
+    if len(schema.Problems) == 0 {
+        return nil;
+    }
+    return bpmerror.NewKind(bpmerror.ManifestInvalid, nil, "Error: The file " + schema.File + " is not valid\n" + strings.Join(schema.Problems, "\n"))
+}
@@ -0,0 +1,151 @@
+package main;
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestBpmSchemaValidate(t *testing.T) {
+    commit := "0123456789abcdef0123456789abcdef01234567"
+    tests := []struct {
+        name string
+        data string
+        lenient bool
+        problems []string
+        expectedWarnings []string
+    }{
+        {
+            name: "valid",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a.git", "commit": "` + commit + `", "exclude": ["b"], "mirrors": ["https://mirror.example.com/a.git"]}}, "scripts": {"postinstall": "make"}, "overrides": {"a>b": {"commit": "` + commit + `"}}}`,
+        },
+        {
+            name: "local commit",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a.git", "commit": "local"}}}`,
+        },
+        {
+            name: "unknown field with a suggestion",
+            data: `{"name": "app", "version": "1.0.0", "dependencys": {}}`,
+            problems: []string{`bpm.json:1:37: unknown field "dependencys" in the bpm file. Did you mean "dependencies"?`},
+        },
+        {
+            name: "version",
+            data: `{"name": "app", "version": "1.0"}`,
+            problems: []string{`bpm.json:1:28: the version "1.0" is not a semantic version`},
+        },
+        {
+            name: "commit",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a.git", "commit": "master"}}}`,
+            problems: []string{`the commit "master" is not a commit hash in dependency a`},
+        },
+        {
+            name: "url",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "ssh://host/a.git", "commit": "` + commit + `"}}}`,
+            problems: []string{`the url "ssh://host/a.git" must be an http url or a relative url in dependency a`},
+        },
+        {
+            name: "mirror",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a.git", "commit": "` + commit + `", "mirrors": ["http:// bad"]}}}`,
+            problems: []string{`contains whitespace in the mirrors of dependency a`},
+        },
+        {
+            name: "dependency in two groups",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {}}, "devDependencies": {"a": {}}}`,
+            problems: []string{`the dependency a is in both dependencies and devDependencies`},
+        },
+        {
+            name: "override without a commit",
+            data: `{"name": "app", "version": "1.0.0", "overrides": {"a>": {"url": "../a.git"}}}`,
+            problems: []string{`the override a> must be a module name or module names separated by >`, `no commit specified in override a>`},
+        },
+        {
+            name: "unknown script",
+            data: `{"name": "app", "version": "1.0.0", "scripts": {"preinstal": "make"}}`,
+            problems: []string{`unknown field "preinstal" in the scripts. Did you mean "preinstall"?`},
+        },
+        {
+            name: "unexpected end",
+            data: `{"name": "app", "version": "1.0.0"`,
+            problems: []string{`unexpected end of the file`},
+        },
+        {
+            name: "data after the end",
+            data: `{"name": "app", "version": "1.0.0"} {}`,
+            problems: []string{`after the end of the bpm file`},
+        },
+        {
+            name: "unknown dependency field is a warning",
+            data: `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a.git", "commit": "` + commit + `", "extra": true}}}`,
+            lenient: true,
+            expectedWarnings: []string{`unknown field "extra" in dependency a`},
+        },
+        {
+            name: "invalid values are warnings",
+            data: `{"name": "mortar", "version": "1.0", "dependencies": {"a": {"url": "ssh://host/a.git", "commit": "master"}, "a": {"url": "../a.git", "commit": "abc", "exclude": [""]}}}`,
+            lenient: true,
+            expectedWarnings: []string{
+                `bpm.json:1:31: the version "1.0" is not a semantic version`,
+                `the url "ssh://host/a.git" must be an http url or a relative url in dependency a`,
+                `the commit "master" is not a commit hash in dependency a`,
+                `duplicate key "a" in the dependencies`,
+                `the commit "abc" is not a commit hash in dependency a`,
+                `the exclude list of dependency a must only contain non empty strings`,
+            },
+        },
+        {
+            name: "values of the wrong type are problems",
+            data: `{"name": "mortar", "version": "1.0.0", "dependencies": {"a": {"url": 1, "commit": "` + commit + `", "exclude": [2]}}}`,
+            lenient: true,
+            problems: []string{`the url of dependency a must be a string`, `the exclude list of dependency a must only contain non empty strings`},
+        },
+        {
+            name: "syntax errors are problems",
+            data: `{"name": "mortar", "version": "1.0"`,
+            lenient: true,
+            problems: []string{`unexpected end of the file`},
+            expectedWarnings: []string{`the version "1.0" is not a semantic version`},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            schema := &BpmSchema{File: "bpm.json", Lenient: test.lenient}
+            err := schema.Validate([]byte(test.data))
+            if len(test.problems) == 0 && err != nil {
+                t.Fatalf("Expected no problems but got %v", err)
+            }
+            if len(test.problems) > 0 && err == nil {
+                t.Fatalf("Expected the problems %v but the data is valid", test.problems)
+            }
+            if len(schema.Problems) != len(test.problems) {
+                t.Fatalf("Expected %d problems but got %v", len(test.problems), schema.Problems)
+            }
+            for i, problem := range test.problems {
+                if !strings.Contains(schema.Problems[i], problem) {
+                    t.Errorf("Expected the problem %q to contain %q", schema.Problems[i], problem)
+                }
+            }
+            if len(schema.Warnings) != len(test.expectedWarnings) {
+                t.Fatalf("Expected %d warnings but got %v", len(test.expectedWarnings), schema.Warnings)
+            }
+            for i, warning := range test.expectedWarnings {
+                if !strings.Contains(schema.Warnings[i], warning) {
+                    t.Errorf("Expected the warning %q to contain %q", schema.Warnings[i], warning)
+                }
+            }
+        })
+    }
+}
+
+func TestLoadDependencyData(t *testing.T) {
+    bpm := &BpmData{}
+    err := bpm.LoadDependencyData("bpm.json", []byte(`{"name": "mortar", "version": "1.0", "dependencies": {"a": {"url": "../a.git", "commit": "abc", "extra": true}}}`))
+    if err != nil {
+        t.Fatalf("Expected the invalid values of a dependency to only be warnings but got %v", err)
+    }
+    if bpm.Name != "mortar" || bpm.Dependencies["a"] == nil || bpm.Dependencies["a"].Commit != "abc" {
+        t.Errorf("Expected the bpm.json of the dependency to be loaded")
+    }
+    err = (&BpmData{}).LoadData("bpm.json", []byte(`{"name": "app", "version": "1.0"}`))
+    if err == nil {
+        t.Errorf("Expected the invalid version of the project to be an error")
+    }
+}
@@ -1,20 +1,65 @@
 package main;
 
 import (
-    "fmt"
     "os"
+    "fmt"
     "path"
-    "bpmerror"
+    "sort"
     "io/ioutil"
+    "path/filepath"
+    "bpmerror"
 )
 
 type CleanCommand struct {
+    Reclaimed int64
+    ReclaimedByModule map[string]int64
 }
 
 func (cmd *CleanCommand) Name() string {
     return "clean"
 }
 
+// Returns the total size of the files in a folder
+func GetFolderSize(folder string) int64 {
+    var size int64
+    filepath.Walk(folder, func(file string, info os.FileInfo, err error) error {
+        if err == nil && !info.IsDir() {
+            size += info.Size()
+        }
+        return nil
+    })
+    return size;
+}
+
+func FormatSize(size int64) string {
+    units := []string{"B", "KB", "MB", "GB", "TB"}
+    value := float64(size)
+    unit := 0
+    for value >= 1024 && unit < len(units) - 1 {
+        value = value / 1024
+        unit++
+    }
+    if unit == 0 {
+        return fmt.Sprintf("%d %s", size, units[unit]);
+    }
+    return fmt.Sprintf("%.1f %s", value, units[unit]);
+}
+
+func (cmd *CleanCommand) remove(moduleName string, pathToRemove string) {
+    size := GetFolderSize(pathToRemove)
+    cmd.Reclaimed += size
+    if cmd.ReclaimedByModule == nil {
+        cmd.ReclaimedByModule = make(map[string]int64)
+    }
+    cmd.ReclaimedByModule[moduleName] += size
+    if Options.DryRun {
+        Log.Info("Would remove item " + pathToRemove + " (" + FormatSize(size) + ")")
+        return;
+    }
+    Log.Info("Removing item " + pathToRemove + " (" + FormatSize(size) + ")")
+    os.RemoveAll(pathToRemove);
+}
+
 func (cmd *CleanCommand) Trim() (error) {
     err := Options.DoesBpmFileExist();
     if err != nil {
@@ -23,39 +68,74 @@ func (cmd *CleanCommand) Trim() (error) {
     bpm := &BpmData{};
     err = bpm.LoadFile(Options.BpmFileName);
     if err != nil {
-        return bpmerror.New(nil, "Error: There was a problem loading the bpm.json file")
-    }
-    if !bpm.HasDependencies() {
-        fmt.Println("There are no dependencies. Done.")
-        return nil;
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
     }
 
+    // Mark every module that is reachable from the bpm.json and then sweep the rest
     cache := &CleanCache{Items:make([]*CleanCacheItem, 0)};
-    err = cache.Build(bpm)
+    dependencyOverrides.Load(bpm)
+    cache.Path.Push(bpm.Name, "")
+    cache.Build(bpm)
     entries, _ := ioutil.ReadDir(Options.BpmCachePath)
     for _, entry := range entries {
         if entry.IsDir() {
             if !cache.NameExists(entry.Name()) {
-                pathToRemove := path.Join(Options.BpmCachePath, entry.Name());
-                fmt.Println("Removing item " + pathToRemove)
-                os.RemoveAll(pathToRemove);
+                cmd.remove(entry.Name(), path.Join(Options.BpmCachePath, entry.Name()));
                 continue;
             }
             commitEntries, _ := ioutil.ReadDir(path.Join(Options.BpmCachePath, entry.Name()))
             for _, commitEntry := range commitEntries {
-                if commitEntry.IsDir() && !cache.Exists(entry.Name(), commitEntry.Name()) {
-                    pathToRemove := path.Join(Options.BpmCachePath, entry.Name(), commitEntry.Name());
-                    fmt.Println("Removing item " + pathToRemove)
-                    os.RemoveAll(pathToRemove)
+                // The install folder is the copy of a module that npm installs and is kept while the module is used
+                if !commitEntry.IsDir() || commitEntry.Name() == Options.InstallModuleName || cache.Exists(entry.Name(), commitEntry.Name()) {
+                    continue;
                 }
+                cmd.remove(entry.Name(), path.Join(Options.BpmCachePath, entry.Name(), commitEntry.Name()))
             }
         }
     }
+    cmd.report()
     return nil;
 }
 
+func (cmd *CleanCommand) report() {
+    names := make([]string, 0, len(cmd.ReclaimedByModule))
+    for name := range cmd.ReclaimedByModule {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        Log.Info("  " + name + " " + FormatSize(cmd.ReclaimedByModule[name]))
+    }
+    if Options.DryRun {
+        Log.Info("Would reclaim " + FormatSize(cmd.Reclaimed))
+    } else {
+        Log.Info("Reclaimed " + FormatSize(cmd.Reclaimed))
+    }
+}
+
 func (cmd *CleanCommand) Clean() (error) {
+    if !PathExists(Options.BpmCachePath) {
+        Log.Info("The " + Options.BpmCachePath + " folder does not exist. Done.")
+        return nil;
+    }
+    size := GetFolderSize(Options.BpmCachePath)
+    if Options.DryRun {
+        cmd.Reclaimed = size
+        Log.Info("Would remove the " + Options.BpmCachePath + " folder")
+        cmd.report()
+        return nil;
+    }
+    confirmed, err := Confirm("Remove the " + Options.BpmCachePath + " folder (" + FormatSize(size) + ")?")
+    if err != nil {
+        return err;
+    }
+    if !confirmed {
+        Log.Info("The " + Options.BpmCachePath + " folder was not removed")
+        return nil;
+    }
     os.RemoveAll(Options.BpmCachePath);
+    cmd.Reclaimed = size
+    cmd.report()
     return nil;
 }
 
@@ -69,11 +149,15 @@ func (cmd *CleanCommand) Execute() (error) {
 
 type CleanCache struct {
     Items []*CleanCacheItem
+    Path DependencyPath
 }
 
 type CleanCacheItem struct {
     Name string
     Commit string
+    // The module was marked below an exclusion or with override keys that have parents, so it is marked again for the
+    // other parents
+    Excluding bool
 }
 
 func (tc *CleanCache) Add(item *CleanCacheItem) {
@@ -95,33 +179,54 @@ func (tc *CleanCache) NameExists(name string) bool {
 }
 
 func (tc *CleanCache) Exists(name string, commit string) bool {
-    if tc.Items == nil || len(tc.Items) == 0 {
-        return false;
-    }
+    return tc.Get(name, commit) != nil;
+}
+
+func (tc *CleanCache) Get(name string, commit string) *CleanCacheItem {
     for _, item := range tc.Items {
         if item.Name == name && item.Commit == commit {
-            return true;
+            return item;
         }
     }
-    return false;
+    return nil;
 }
 
-func (tc *CleanCache) Build(bpm *BpmData) (error) {
+// Mark every module in the dependency graph. Local folders are only marked with the --keep-local option, and then the
+// dependencies of the local folder are marked as well. A module that closes a cycle is marked but not walked again.
+func (tc *CleanCache) Build(bpm *BpmData) {
     // Always process the keys sorted by name so the processing is consistent
-    sortedKeys := bpm.GetSortedKeys();
+    sortedKeys := bpm.GetInstallKeys(tc.Path.IsRoot());
     for _, depName := range sortedKeys {
-        depItem := bpm.Dependencies[depName]
-        // Delete previous cached items
-        tcItem := &CleanCacheItem{Name: depName, Commit: depItem.Commit}
-        tc.Add(tcItem);
-        moduleBpm := &BpmData{};
-        moduleBpmFilePath := path.Join(Options.BpmCachePath, depName, depItem.Commit, Options.BpmFileName);
-        // It should be expected that the bpm.json file may not exist and this isn't a fatal error, just move on.
-        err := moduleBpm.LoadFile(moduleBpmFilePath);
-        if err != nil {
-            return nil;
+        if tc.Path.ExcludedBy(depName) != "" {
+            continue;
+        }
+        // The commit that is forced by an override is the one that is installed
+        depItem, _ := dependencyOverrides.Apply(depName, &tc.Path, bpm.Dependencies[depName])
+        folders := []string{depItem.Commit}
+        if Options.KeepLocal && PathExists(path.Join(Options.BpmCachePath, depName, Options.LocalModuleName)) {
+            folders = append(folders, Options.LocalModuleName)
+        }
+        excluding := tc.Path.HasExcludes() || len(depItem.Exclude) > 0 || dependencyOverrides.HasParents()
+        for _, folder := range folders {
+            existing := tc.Get(depName, folder)
+            if existing != nil && !existing.Excluding {
+                continue;
+            }
+            if existing == nil {
+                tc.Add(&CleanCacheItem{Name: depName, Commit: folder, Excluding: excluding});
+            } else {
+                existing.Excluding = excluding
+            }
+            moduleBpm := &BpmData{};
+            moduleBpmFilePath := path.Join(Options.BpmCachePath, depName, folder, Options.BpmFileName);
+            // It should be expected that the bpm.json file may not exist and this isn't a fatal error, just move on.
+            err := moduleBpm.LoadDependencyFile(moduleBpmFilePath);
+            if err != nil || tc.Path.FindCycle(moduleBpm.Name, folder) != "" {
+                continue;
+            }
+            tc.Path.PushExcluding(moduleBpm.Name, folder, depItem.Exclude)
+            tc.Build(moduleBpm);
+            tc.Path.Pop()
         }
-        return tc.Build(moduleBpm);
     }
-    return nil
 }
@@ -0,0 +1,114 @@
+package main;
+
+import (
+    "os"
+    "sort"
+    "strings"
+    "testing"
+    "io/ioutil"
+    "path/filepath"
+)
+
+// Write a bpm.json with the name and the dependencies, which are given as the json of the dependencies object
+func writeManifest(t *testing.T, folder string, name string, dependencies string) {
+    os.MkdirAll(folder, 0777)
+    data := `{"name": "` + name + `", "version": "1.0.0", "dependencies": {` + dependencies + `}}`
+    err := ioutil.WriteFile(filepath.Join(folder, "bpm.json"), []byte(data), 0666)
+    if err != nil {
+        t.Fatal(err)
+    }
+}
+
+func loadManifest(t *testing.T, folder string) *BpmData {
+    bpm := &BpmData{}
+    err := bpm.LoadFile(filepath.Join(folder, "bpm.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return bpm;
+}
+
+// Use the folder as the bpm cache and restore the options after the test
+func useBpmCache(t *testing.T, folder string) func() {
+    options := Options
+    overrides := dependencyOverrides
+    Options.BpmCachePath = folder
+    return func() {
+        Options = options
+        dependencyOverrides = overrides
+    };
+}
+
+func TestCleanCacheBuild(t *testing.T) {
+    tests := []struct {
+        name string
+        root string
+        modules map[string]string
+        expected []string
+    }{
+        {
+            name: "shared module below an exclusion",
+            root: `"a": {"url": "../a", "commit": "aaaaaaa", "exclude": ["x"]}, "b": {"url": "../b", "commit": "bbbbbbb"}`,
+            modules: map[string]string{
+                "a/aaaaaaa": `"s": {"url": "../s", "commit": "5555555"}`,
+                "b/bbbbbbb": `"s": {"url": "../s", "commit": "5555555"}`,
+                "s/5555555": `"x": {"url": "../x", "commit": "7777777"}`,
+                "x/7777777": ``,
+            },
+            expected: []string{"a@aaaaaaa", "b@bbbbbbb", "s@5555555", "x@7777777"},
+        },
+        {
+            name: "excluded for every parent",
+            root: `"a": {"url": "../a", "commit": "aaaaaaa", "exclude": ["x"]}`,
+            modules: map[string]string{
+                "a/aaaaaaa": `"s": {"url": "../s", "commit": "5555555"}`,
+                "s/5555555": `"x": {"url": "../x", "commit": "7777777"}`,
+                "x/7777777": ``,
+            },
+            expected: []string{"a@aaaaaaa", "s@5555555"},
+        },
+        {
+            name: "cycle",
+            root: `"a": {"url": "../a", "commit": "aaaaaaa"}`,
+            modules: map[string]string{
+                "a/aaaaaaa": `"b": {"url": "../b", "commit": "bbbbbbb"}`,
+                "b/bbbbbbb": `"a": {"url": "../a", "commit": "aaaaaaa"}`,
+            },
+            expected: []string{"a@aaaaaaa", "b@bbbbbbb"},
+        },
+        {
+            name: "cycle below an exclusion",
+            root: `"a": {"url": "../a", "commit": "aaaaaaa", "exclude": ["x"]}`,
+            modules: map[string]string{
+                "a/aaaaaaa": `"b": {"url": "../b", "commit": "bbbbbbb"}`,
+                "b/bbbbbbb": `"a": {"url": "../a", "commit": "aaaaaaa"}, "x": {"url": "../x", "commit": "7777777"}`,
+                "x/7777777": ``,
+            },
+            expected: []string{"a@aaaaaaa", "b@bbbbbbb"},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            temp := makeTempDir(t)
+            defer os.RemoveAll(temp)
+            defer useBpmCache(t, filepath.Join(temp, "bpm_modules"))()
+            writeManifest(t, temp, "root", test.root)
+            for module, dependencies := range test.modules {
+                writeManifest(t, filepath.Join(temp, "bpm_modules", filepath.FromSlash(module)), strings.Split(module, "/")[0], dependencies)
+            }
+            bpm := loadManifest(t, temp)
+            dependencyOverrides.Load(bpm)
+            cache := &CleanCache{Items: make([]*CleanCacheItem, 0)}
+            cache.Path.Push(bpm.Name, "")
+            cache.Build(bpm)
+            marked := []string{}
+            for _, item := range cache.Items {
+                marked = append(marked, item.Name + "@" + item.Commit)
+            }
+            sort.Strings(marked)
+            if strings.Join(marked, " ") != strings.Join(test.expected, " ") {
+                t.Errorf("Expected %v to be marked but got %v", test.expected, marked)
+            }
+        })
+    }
+}
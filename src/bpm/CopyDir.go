@@ -3,17 +3,34 @@ package main
 
 import (
 	"io"
+	"fmt"
+	"bytes"
+	"crypto/sha1"
 	"io/ioutil"
-	"log"
 	"os"
+	"path"
 	"strings"
+	"bpmerror"
 )
 
 type CopyDir struct {
+	// The pipe separated list of the names and glob patterns that are not copied
 	Exclude string
+	// The name of the ignore file in the source folder, for example .bpmignore
+	IgnoreFile string
+	// Only copy the files that changed and delete the files that are not in the source
+	Sync bool
+	// Compare the contents of the files with the same size and modification time when syncing
+	Checksum bool
+	Copied int
+	Removed int
+	Unchanged int
+	ignore *IgnoreList
+	errors []string
 }
 
-// Copies file source to destination dest.
+// Copies file source to destination dest. The modification time is preserved so the file can be compared when syncing.
+// The close of the destination is checked since the data is only written completely when it is closed.
 func (cp *CopyDir) CopyFile(source string, dest string) (err error) {
 	sf, err := os.Open(source)
 	if err != nil {
@@ -24,36 +41,109 @@ func (cp *CopyDir) CopyFile(source string, dest string) (err error) {
 	if err != nil {
 		return err
 	}
-	defer df.Close()
 	_, err = io.Copy(df, sf)
-	if err == nil {
-		si, err := os.Stat(source)
-		if err == nil {
-			err = os.Chmod(dest, si.Mode())
-		}
-
+	if closeErr := df.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	si, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(dest, si.Mode())
+	if err != nil {
+		return err
 	}
-	return
+	return os.Chtimes(dest, si.ModTime(), si.ModTime())
 }
 
+func (cp *CopyDir) fileHash(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hash := sha1.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
 
-func (cp *CopyDir) contains(s []string, e string) bool {
-    for _, a := range s {
-        if strings.Compare(a, e) == 0 {
-            return true
-        }
-    }
-    return false
+// Returns true if the destination file is the same as the source file
+func (cp *CopyDir) isUnchanged(source os.FileInfo, sourcePath string, destPath string) bool {
+	dest, err := os.Lstat(destPath)
+	if err != nil || !dest.Mode().IsRegular() {
+		return false
+	}
+	if dest.Size() != source.Size() || !dest.ModTime().Equal(source.ModTime()) {
+		return false
+	}
+	if !cp.Checksum {
+		return true
+	}
+	sourceHash, err := cp.fileHash(sourcePath)
+	if err != nil {
+		return false
+	}
+	destHash, err := cp.fileHash(destPath)
+	return err == nil && bytes.Equal(sourceHash, destHash)
 }
 
 // Recursively copies a directory tree, attempting to preserve permissions.
-// Source directory must exist, destination directory must *not* exist.
+// Source directory must exist. In sync mode the destination is updated to match the source. Every file that could
+// not be copied is reported in the returned error.
 func (cp *CopyDir) Copy(source string, dest string) (err error) {
+	cp.Copied = 0
+	cp.Removed = 0
+	cp.Unchanged = 0
+	cp.errors = []string{}
+	cp.ignore, err = NewIgnoreList(cp.Exclude).WithFile(source, cp.IgnoreFile)
+	if err != nil {
+		return err
+	}
+	err = cp.copy(source, dest, "")
+	if err != nil {
+		cp.fail(source, err)
+	}
+	if len(cp.errors) > 0 {
+		return bpmerror.New(nil, fmt.Sprintf("Error: %d files could not be copied from %s to %s\n%s", len(cp.errors), source, dest, strings.Join(cp.errors, "\n")))
+	}
+	return nil
+}
+
+// Describes what the last sync changed
+func (cp *CopyDir) Summary() string {
+	return fmt.Sprintf("%d copied, %d removed, %d unchanged", cp.Copied, cp.Removed, cp.Unchanged)
+}
 
-	var excludeNames = []string{}
-	if cp.Exclude != "" {
-		excludeNames = strings.Split(cp.Exclude, "|")
+func (cp *CopyDir) fail(file string, err error) {
+	cp.errors = append(cp.errors, file + ": " + err.Error())
+}
+
+// Copies a link as a link with the same target. The target is not copied.
+func (cp *CopyDir) CopyLink(source string, dest string) error {
+	target, err := os.Readlink(source)
+	if err != nil {
+		return err
 	}
+	os.RemoveAll(dest)
+	return os.Symlink(target, dest)
+}
+
+func (cp *CopyDir) isSameLink(source string, dest string) bool {
+	target, err := os.Readlink(source)
+	if err != nil {
+		return false
+	}
+	existing, err := os.Readlink(dest)
+	return err == nil && existing == target
+}
+
+func (cp *CopyDir) copy(source string, dest string, relativePath string) (err error) {
 
 	// get properties of source dir
 	fi, err := os.Stat(source)
@@ -63,16 +153,11 @@ func (cp *CopyDir) Copy(source string, dest string) (err error) {
 	if !fi.IsDir() {
 		return &CustomError{"Source is not a directory"}
 	}
-	// ensure dest dir does not already exist
+	// A file or a link where the folder should be is replaced
+	if di, err := os.Lstat(dest); err == nil && !di.IsDir() {
+		os.Remove(dest)
+	}
 	_, err = os.Open(dest)
-	//if !os.IsNotExist(err) {
-	//	return &CustomError{"Destination already exists"}
-	//}
-	// create dest dir
-	//err = os.MkdirAll(dest, fi.Mode())
-	//if err != nil {
-	//	return err
-	//}
 	if os.IsNotExist(err) {
 		// create dest dir
 		err = os.MkdirAll(dest, fi.Mode())
@@ -81,31 +166,72 @@ func (cp *CopyDir) Copy(source string, dest string) (err error) {
 		}
 	}
 	entries, err := ioutil.ReadDir(source)
+	if err != nil {
+		return err
+	}
+	sourceNames := make(map[string]bool)
 	for _, entry := range entries {
 
-		// Skip file names that match the excluded names
-		if cp.contains(excludeNames, entry.Name()) {
+		// Skip the files that match the exclude list or the ignore file
+		entryPath := path.Join(relativePath, entry.Name())
+		if cp.ignore.Match(entryPath, entry.IsDir()) {
 			continue;
 		}
+		sourceNames[entry.Name()] = true
 
 		sfp := source + "/" + entry.Name()
 		dfp := dest + "/" + entry.Name()
-		if entry.IsDir() {
-			copyDir := CopyDir{Exclude:cp.Exclude}
-			err = copyDir.Copy(sfp, dfp);
+		if entry.Mode() & os.ModeSymlink != 0 {
+			// Links are copied as links so they keep pointing to the same target
+			if cp.Sync && cp.isSameLink(sfp, dfp) {
+				cp.Unchanged++
+				continue
+			}
+			err = cp.CopyLink(sfp, dfp)
+			if err != nil {
+				cp.fail(sfp, err)
+			} else {
+				cp.Copied++
+			}
+		} else if entry.IsDir() {
+			err = cp.copy(sfp, dfp, entryPath);
 			if err != nil {
-				log.Println(err)
+				cp.fail(sfp, err)
 			}
+		} else if cp.Sync && cp.isUnchanged(entry, sfp, dfp) {
+			cp.Unchanged++
 		} else {
+			if di, err := os.Lstat(dfp); err == nil && (di.IsDir() || di.Mode() & os.ModeSymlink != 0) {
+				os.RemoveAll(dfp)
+			}
 			// perform copy
+			Log.Trace("Copying", sfp)
 			err = cp.CopyFile(sfp, dfp)
 			if err != nil {
-				log.Println(err)
+				cp.fail(sfp, err)
+			} else {
+				cp.Copied++
 			}
 		}
 
 	}
-	return
+	if cp.Sync {
+		// Delete the files that are no longer in the source. The ignored files are left alone since they were never copied.
+		destEntries, _ := ioutil.ReadDir(dest)
+		for _, entry := range destEntries {
+			if sourceNames[entry.Name()] || cp.ignore.Match(path.Join(relativePath, entry.Name()), entry.IsDir()) {
+				continue
+			}
+			Log.Trace("Removing", dest + "/" + entry.Name())
+			err = os.RemoveAll(dest + "/" + entry.Name())
+			if err != nil {
+				cp.fail(dest + "/" + entry.Name(), err)
+			} else {
+				cp.Removed++
+			}
+		}
+	}
+	return nil
 }
 
 // A struct for returning custom error messages
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+	"testing"
+	"io/ioutil"
+	"path/filepath"
+)
+
+func TestCopyDirSync(t *testing.T) {
+	temp := makeTempDir(t)
+	defer os.RemoveAll(temp)
+	source := filepath.Join(temp, "source")
+	dest := filepath.Join(temp, "dest")
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(name string, content string) {
+		writeFiles(t, source, map[string]string{name: content})
+		os.Chtimes(filepath.Join(source, name), modTime, modTime)
+	}
+	// Each step changes the source and syncs it to the destination
+	tests := []struct {
+		name string
+		change func()
+		checksum bool
+		copied int
+		removed int
+		unchanged int
+	}{
+		{name: "first copy", change: func() {
+			write("a.txt", "a")
+			write("dir/b.txt", "b")
+		}, copied: 2},
+		{name: "nothing changed", change: func() {}, unchanged: 2},
+		{name: "changed file", change: func() { write("a.txt", "aa") }, copied: 1, unchanged: 1},
+		{name: "removed file", change: func() { os.Remove(filepath.Join(source, "dir", "b.txt")) }, removed: 1, unchanged: 1},
+		{name: "removed folder", change: func() { os.Remove(filepath.Join(source, "dir")) }, removed: 1, unchanged: 1},
+		{name: "extra file in the destination", change: func() {
+			writeFiles(t, dest, map[string]string{"extra/c.txt": "c"})
+		}, removed: 1, unchanged: 1},
+		// The content changed but the size and the modification time are the same
+		{name: "same size and time", change: func() { write("a.txt", "bb") }, unchanged: 1},
+		{name: "checksum", change: func() {}, checksum: true, copied: 1},
+		{name: "folder replaces a file", change: func() {
+			os.Remove(filepath.Join(source, "a.txt"))
+			write("a.txt/d.txt", "d")
+		}, copied: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.change()
+			cp := CopyDir{Sync: true, Checksum: test.checksum}
+			err := cp.Copy(source, dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cp.Copied != test.copied || cp.Removed != test.removed || cp.Unchanged != test.unchanged {
+				t.Errorf("Expected %d copied, %d removed, %d unchanged but got %s", test.copied, test.removed, test.unchanged, cp.Summary())
+			}
+		})
+	}
+	dat, err := ioutil.ReadFile(filepath.Join(dest, "a.txt", "d.txt"))
+	if err != nil || string(dat) != "d" {
+		t.Errorf("Expected the destination to match the source but got %q %v", string(dat), err)
+	}
+	for _, name := range []string{"dir", "extra"} {
+		if PathExists(filepath.Join(dest, name)) {
+			t.Errorf("Expected %s to be removed from the destination", name)
+		}
+	}
+}
+
+// Without sync every file is copied and the files that are not in the source are kept
+func TestCopyDirCopy(t *testing.T) {
+	temp := makeTempDir(t)
+	defer os.RemoveAll(temp)
+	source := filepath.Join(temp, "source")
+	dest := filepath.Join(temp, "dest")
+	writeFiles(t, source, map[string]string{"a.txt": "a", "dir/b.sh": "b"})
+	os.Chmod(filepath.Join(source, "dir", "b.sh"), 0755)
+	writeFiles(t, dest, map[string]string{"extra.txt": "extra"})
+	for i := 0; i < 2; i++ {
+		cp := CopyDir{}
+		err := cp.Copy(source, dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cp.Copied != 2 || cp.Removed != 0 || cp.Unchanged != 0 {
+			t.Errorf("Expected 2 copied but got %s", cp.Summary())
+		}
+	}
+	if !PathExists(filepath.Join(dest, "extra.txt")) {
+		t.Errorf("Expected the extra file to be kept")
+	}
+	info, err := os.Stat(filepath.Join(dest, "dir", "b.sh"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("Expected the mode of the file to be kept but got %v %v", info, err)
+	}
+	cp := CopyDir{}
+	err = cp.Copy(filepath.Join(temp, "missing"), dest)
+	if err == nil {
+		t.Errorf("Expected an error for a missing source")
+	}
+}
+
+func TestCopyDirIgnore(t *testing.T) {
+	temp := makeTempDir(t)
+	defer os.RemoveAll(temp)
+	source := filepath.Join(temp, "source")
+	dest := filepath.Join(temp, "dest")
+	writeFiles(t, source, map[string]string{
+		".bpmignore": "docs/\n!keep.log",
+		"index.js": "index",
+		"debug.log": "log",
+		"keep.log": "keep",
+		"docs/guide.md": "guide",
+		"lib/docs/api.md": "api",
+		".git/HEAD": "ref: refs/heads/master",
+	})
+	// An ignored file in the destination is left alone by the sync
+	writeFiles(t, dest, map[string]string{"debug.log": "local log"})
+	cp := CopyDir{Exclude: ".git|*.log", IgnoreFile: ".bpmignore", Sync: true}
+	err := cp.Copy(source, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		exists bool
+	}{
+		{name: ".bpmignore", exists: true},
+		{name: "index.js", exists: true},
+		{name: "keep.log", exists: true},
+		{name: "debug.log", exists: true},
+		{name: "docs", exists: false},
+		{name: "lib/docs", exists: false},
+		{name: ".git", exists: false},
+	}
+	for _, test := range tests {
+		if PathExists(filepath.Join(dest, filepath.FromSlash(test.name))) != test.exists {
+			t.Errorf("Expected %s to exist to be %v", test.name, test.exists)
+		}
+	}
+	dat, _ := ioutil.ReadFile(filepath.Join(dest, "debug.log"))
+	if string(dat) != "local log" {
+		t.Errorf("Expected the ignored file in the destination to be kept but got %q", string(dat))
+	}
+}
+
+func TestCopyDirLinks(t *testing.T) {
+	temp := makeTempDir(t)
+	defer os.RemoveAll(temp)
+	source := filepath.Join(temp, "source")
+	dest := filepath.Join(temp, "dest")
+	writeFiles(t, source, map[string]string{"lib/index.js": "index", "outside/file.txt": "outside"})
+	for name, target := range map[string]string{"main.js": "lib/index.js", "lib-link": "lib", "missing": "does-not-exist", "up": "../outside"} {
+		err := os.Symlink(target, filepath.Join(source, name))
+		if err != nil {
+			t.Skip("links are not supported", err)
+		}
+	}
+	cp := CopyDir{Sync: true}
+	err := cp.Copy(source, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{"main.js": "lib/index.js", "lib-link": "lib", "missing": "does-not-exist", "up": "../outside"} {
+		existing, err := os.Readlink(filepath.Join(dest, name))
+		if err != nil || existing != target {
+			t.Errorf("Expected %s to be a link to %s but got %q %v", name, target, existing, err)
+		}
+	}
+	// The same links are unchanged and a changed link is replaced
+	os.Remove(filepath.Join(source, "main.js"))
+	os.Symlink("outside/file.txt", filepath.Join(source, "main.js"))
+	err = cp.Copy(source, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Copied != 1 {
+		t.Errorf("Expected only the changed link to be copied but got %s", cp.Summary())
+	}
+	existing, _ := os.Readlink(filepath.Join(dest, "main.js"))
+	if existing != "outside/file.txt" {
+		t.Errorf("Expected the changed link to be replaced but got %q", existing)
+	}
+}
+
+// Every file that cannot be copied is reported and the other files are still copied
+func TestCopyDirErrors(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("the files can always be read as root")
+	}
+	temp := makeTempDir(t)
+	defer os.RemoveAll(temp)
+	source := filepath.Join(temp, "source")
+	writeFiles(t, source, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"})
+	os.Chmod(filepath.Join(source, "a.txt"), 0)
+	os.Chmod(filepath.Join(source, "b.txt"), 0)
+	cp := CopyDir{}
+	err := cp.Copy(source, filepath.Join(temp, "dest"))
+	if err == nil || !strings.Contains(err.Error(), "2 files could not be copied") {
+		t.Errorf("Expected the two files to be reported but got %v", err)
+	}
+	if cp.Copied != 1 {
+		t.Errorf("Expected the other file to be copied but got %s", cp.Summary())
+	}
+}
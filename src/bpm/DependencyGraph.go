@@ -0,0 +1,129 @@
+package main;
+
+import (
+    "sort"
+    "strings"
+)
+
+// A module at a commit that was reached while resolving the dependencies. Every parent that requires the module is recorded.
+type DependencyNode struct {
+    Name string
+    Commit string
+    Version string
+    Url string
+    Source string
+    Cache string
+    Local bool
+    // The key of the override in the root bpm.json that forced the commit
+    Override string
+    // The module was processed below an exclusion or with override keys that have parents, so it is processed again for
+    // the other parents
+    Excluding bool
+    Parents []string
+}
+
+// The resolved dependency graph. Each name and commit is processed once, no matter how many paths reach it, unless it
+// was processed below an exclusion.
+type DependencyGraph struct {
+    Nodes map[string]*DependencyNode
+    // The modules that were excluded and the modules that excluded them
+    Excluded map[string][]string
+}
+
+func (graph *DependencyGraph) Key(name string, commit string) string {
+    return name + "@" + commit;
+}
+
+func (graph *DependencyGraph) Get(name string, commit string) (*DependencyNode, bool) {
+    node, exists := graph.Nodes[graph.Key(name, commit)]
+    return node, exists;
+}
+
+// Add the module. The parents are kept when the module is processed again.
+func (graph *DependencyGraph) Add(node *DependencyNode) {
+    if existing, exists := graph.Get(node.Name, node.Commit); exists {
+        for _, parent := range node.Parents {
+            existing.AddParent(parent)
+        }
+        node.Parents = existing.Parents
+    }
+    graph.Nodes[graph.Key(node.Name, node.Commit)] = node
+}
+
+// Returns a copy of the graph. The nodes are copied since their parents change when other modules require them.
+func (graph *DependencyGraph) Clone() DependencyGraph {
+    clone := DependencyGraph{Nodes: make(map[string]*DependencyNode), Excluded: make(map[string][]string)}
+    for key, node := range graph.Nodes {
+        copied := *node
+        copied.Parents = append([]string{}, node.Parents...)
+        clone.Nodes[key] = &copied
+    }
+    for name, excludedBy := range graph.Excluded {
+        clone.Excluded[name] = append([]string{}, excludedBy...)
+    }
+    return clone;
+}
+
+func (node *DependencyNode) AddParent(parent string) {
+    for _, existing := range node.Parents {
+        if existing == parent {
+            return;
+        }
+    }
+    node.Parents = append(node.Parents, parent)
+}
+
+func (graph *DependencyGraph) SortedKeys() []string {
+    keys := make([]string, 0, len(graph.Nodes))
+    for key := range graph.Nodes {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys;
+}
+
+// Log every module in the graph and the parents that require it
+func (graph *DependencyGraph) LogSummary() {
+    if !Log.IsEnabled(LogLevelDebug) {
+        return;
+    }
+    for _, key := range graph.SortedKeys() {
+        node := graph.Nodes[key]
+        Log.Debug(key, "is required by", strings.Join(node.Parents, ", "))
+    }
+}
+
+// Record that a module was excluded by a dependency
+func (graph *DependencyGraph) AddExcluded(name string, excludedBy string) {
+    for _, existing := range graph.Excluded[name] {
+        if existing == excludedBy {
+            return;
+        }
+    }
+    graph.Excluded[name] = append(graph.Excluded[name], excludedBy)
+}
+
+func (graph *DependencyGraph) HasModule(name string) bool {
+    for _, node := range graph.Nodes {
+        if node.Name == name {
+            return true;
+        }
+    }
+    return false;
+}
+
+// Warn about the excluded modules that no other module supplies, since they are not installed at all
+func (graph *DependencyGraph) WarnExcluded() {
+    names := make([]string, 0, len(graph.Excluded))
+    for name := range graph.Excluded {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        if !graph.HasModule(name) {
+            Log.Warn("Warning: The module", name, "is excluded by", strings.Join(graph.Excluded[name], ", "), "and no other module requires it, so it is not installed")
+        }
+    }
+}
+
+var dependencyGraph = DependencyGraph{Nodes: make(map[string]*DependencyNode), Excluded: make(map[string][]string)}
@@ -0,0 +1,72 @@
+package main;
+
+import (
+    "strings"
+)
+
+// The overrides in the root bpm.json force the commit of a module anywhere in the dependency graph. The key is the
+// module name, or the names of the parents and the module separated by > to only override the module below those
+// parents, for example login-client>mortar. The most specific key that matches is used.
+type DependencyOverrides struct {
+    Items map[string]*BpmDependency
+}
+
+func (overrides *DependencyOverrides) Load(bpm *BpmData) {
+    overrides.Items = bpm.Overrides
+}
+
+// Returns true if a key has parents. The commits below a module then depend on the path that reached the module, so the
+// modules are walked again for each path instead of only once per commit.
+func (overrides *DependencyOverrides) HasParents() bool {
+    for key := range overrides.Items {
+        if strings.Contains(key, ">") {
+            return true;
+        }
+    }
+    return false;
+}
+
+// Returns true if the names in the key are the end of the dependency path followed by the module name
+func (overrides *DependencyOverrides) matches(key string, name string, dp *DependencyPath) bool {
+    names := strings.Split(key, ">")
+    if strings.TrimSpace(names[len(names) - 1]) != name {
+        return false;
+    }
+    parents := names[:len(names) - 1]
+    if len(parents) > len(dp.Names) {
+        return false;
+    }
+    offset := len(dp.Names) - len(parents)
+    for i, parent := range parents {
+        if strings.TrimSpace(parent) != dp.Names[offset + i] {
+            return false;
+        }
+    }
+    return true;
+}
+
+// Returns the dependency to use for the module and the key of the override that applied. The key is empty when no
+// override applied and the dependency is returned as is.
+func (overrides *DependencyOverrides) Apply(name string, dp *DependencyPath, item *BpmDependency) (*BpmDependency, string) {
+    match := ""
+    for key := range overrides.Items {
+        if !overrides.matches(key, name, dp) {
+            continue;
+        }
+        if match == "" || strings.Count(key, ">") > strings.Count(match, ">") || (strings.Count(key, ">") == strings.Count(match, ">") && key < match) {
+            match = key
+        }
+    }
+    if match == "" {
+        return item, "";
+    }
+    override := overrides.Items[match]
+    newItem := &BpmDependency{Url: item.Url, Commit: override.Commit, Branch: item.Branch, Exclude: item.Exclude, Mirrors: item.Mirrors, Group: item.Group}
+    if override.Url != "" {
+        newItem.Url = override.Url
+        newItem.Mirrors = override.Mirrors
+    }
+    return newItem, match;
+}
+
+var dependencyOverrides = DependencyOverrides{}
@@ -0,0 +1,95 @@
+package main;
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestDependencyOverridesApply(t *testing.T) {
+    overrides := DependencyOverrides{Items: map[string]*BpmDependency{
+        "mortar": {Commit: "c-mortar"},
+        "login-client>mortar": {Commit: "c-login"},
+        "app > login-client > mortar": {Commit: "c-app-login"},
+        "theme": {Commit: "c-theme", Url: "../theme-fork.git", Mirrors: []string{"https://mirror.example.com/theme-fork.git"}},
+        "z>icons": {Commit: "c-z"},
+        "a>icons": {Commit: "c-a"},
+    }}
+    item := &BpmDependency{Url: "../lib.git", Commit: "c-original", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/lib.git"}, Group: DevDependencyGroup}
+    tests := []struct {
+        name string
+        module string
+        path []string
+        key string
+        expected *BpmDependency
+    }{
+        {
+            name: "no override",
+            module: "other",
+            path: []string{"app"},
+            expected: item,
+        },
+        {
+            name: "module name",
+            module: "mortar",
+            path: []string{"app", "grid"},
+            key: "mortar",
+            expected: &BpmDependency{Url: "../lib.git", Commit: "c-mortar", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/lib.git"}, Group: DevDependencyGroup},
+        },
+        {
+            name: "parent and module name",
+            module: "mortar",
+            path: []string{"root", "login-client"},
+            key: "login-client>mortar",
+            expected: &BpmDependency{Url: "../lib.git", Commit: "c-login", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/lib.git"}, Group: DevDependencyGroup},
+        },
+        {
+            name: "the most specific key with spaces",
+            module: "mortar",
+            path: []string{"app", "login-client"},
+            key: "app > login-client > mortar",
+            expected: &BpmDependency{Url: "../lib.git", Commit: "c-app-login", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/lib.git"}, Group: DevDependencyGroup},
+        },
+        {
+            name: "parents must be at the end of the path",
+            module: "mortar",
+            path: []string{"login-client", "grid"},
+            key: "mortar",
+            expected: &BpmDependency{Url: "../lib.git", Commit: "c-mortar", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/lib.git"}, Group: DevDependencyGroup},
+        },
+        {
+            name: "url replaces the mirrors",
+            module: "theme",
+            path: []string{"app"},
+            key: "theme",
+            expected: &BpmDependency{Url: "../theme-fork.git", Commit: "c-theme", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/theme-fork.git"}, Group: DevDependencyGroup},
+        },
+        {
+            name: "more parents than the path",
+            module: "icons",
+            path: []string{},
+            expected: item,
+        },
+        {
+            name: "same length keys use the first key",
+            module: "icons",
+            path: []string{"a"},
+            key: "a>icons",
+            expected: &BpmDependency{Url: "../lib.git", Commit: "c-a", Branch: "develop", Exclude: []string{"x"}, Mirrors: []string{"https://mirror.example.com/lib.git"}, Group: DevDependencyGroup},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            dp := &DependencyPath{}
+            for _, name := range test.path {
+                dp.Push(name, "")
+            }
+            result, key := overrides.Apply(test.module, dp, item)
+            if key != test.key {
+                t.Errorf("Expected the override %q but got %q", test.key, key)
+            }
+            if !reflect.DeepEqual(result, test.expected) {
+                t.Errorf("Expected %+v but got %+v", test.expected, result)
+            }
+        })
+    }
+}
@@ -0,0 +1,117 @@
+package main;
+
+import (
+    "strings"
+    "bpmerror"
+)
+
+// The chain of modules from the root to the module currently being processed. It is used to detect cycles in the
+// dependency graph. A module that appears twice in the chain is a cycle, even when the commits are different.
+// The modules excluded by each dependency in the chain are not processed below that dependency.
+type DependencyPath struct {
+    Names []string
+    Commits []string
+    Excludes [][]string
+}
+
+func (dp *DependencyPath) Push(name string, commit string) {
+    dp.PushExcluding(name, commit, nil)
+}
+
+// Add the module along with the modules its dependency entry excludes
+func (dp *DependencyPath) PushExcluding(name string, commit string, exclude []string) {
+    dp.Names = append(dp.Names, name)
+    dp.Commits = append(dp.Commits, commit)
+    dp.Excludes = append(dp.Excludes, exclude)
+}
+
+func (dp *DependencyPath) Pop() {
+    if len(dp.Names) == 0 {
+        return;
+    }
+    dp.Names = dp.Names[:len(dp.Names) - 1]
+    dp.Commits = dp.Commits[:len(dp.Commits) - 1]
+    dp.Excludes = dp.Excludes[:len(dp.Excludes) - 1]
+}
+
+// Returns the module in the chain that excludes the module, or an empty string if the module is not excluded
+func (dp *DependencyPath) ExcludedBy(name string) string {
+    for i := len(dp.Names) - 1; i >= 0; i-- {
+        for _, excluded := range dp.Excludes[i] {
+            if excluded == name {
+                return dp.format(i);
+            }
+        }
+    }
+    return "";
+}
+
+// Returns true when a module in the chain excludes modules. The modules processed below it are then missing the
+// excluded modules, so the result depends on the path that reached them and cannot be reused for other paths.
+func (dp *DependencyPath) HasExcludes() bool {
+    for _, exclude := range dp.Excludes {
+        if len(exclude) > 0 {
+            return true;
+        }
+    }
+    return false;
+}
+
+func (dp *DependencyPath) IsEmpty() bool {
+    return len(dp.Names) == 0;
+}
+
+// Returns true when the dependencies being processed are the dependencies of the root project
+func (dp *DependencyPath) IsRoot() bool {
+    return len(dp.Names) == 1;
+}
+
+func (dp *DependencyPath) IndexOf(name string) int {
+    return SliceIndex(len(dp.Names), func(i int) bool { return dp.Names[i] == name });
+}
+
+// Returns the module at the end of the path, which is the parent of the module being processed
+func (dp *DependencyPath) Last() string {
+    if dp.IsEmpty() {
+        return "";
+    }
+    return dp.format(len(dp.Names) - 1);
+}
+
+func (dp *DependencyPath) format(index int) string {
+    if dp.Commits[index] == "" {
+        return dp.Names[index];
+    }
+    return dp.Names[index] + "@" + dp.Commits[index];
+}
+
+// Returns the cycle as text, for example A@c1 → B@c2 → A@c3, or an empty string if adding the module does not close a cycle
+func (dp *DependencyPath) FindCycle(name string, commit string) string {
+    index := dp.IndexOf(name)
+    if index == -1 {
+        return "";
+    }
+    items := []string{}
+    for i := index; i < len(dp.Names); i++ {
+        items = append(items, dp.format(i))
+    }
+    closing := DependencyPath{Names: []string{name}, Commits: []string{commit}, Excludes: [][]string{nil}}
+    items = append(items, closing.format(0))
+    return strings.Join(items, " → ");
+}
+
+// Check if adding the module closes a cycle. Depending on the --cycles option, the cycle is either an error or a warning
+// and true is returned to indicate the dependency should be skipped.
+func (dp *DependencyPath) CheckCycle(name string, commit string) (bool, error) {
+    cycle := dp.FindCycle(name, commit)
+    if cycle == "" {
+        return false, nil;
+    }
+    if Options.Cycles == "break" {
+        Log.Warn("Warning: Ignoring the dependency cycle", cycle)
+        return true, nil;
+    }
+    return true, bpmerror.NewKind(bpmerror.Conflict, nil, "Error: There is a cycle in the dependencies " + cycle)
+}
+
+var dependencyPath = DependencyPath{}
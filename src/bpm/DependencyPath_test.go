@@ -0,0 +1,165 @@
+package main;
+
+import (
+    "testing"
+)
+
+type pathEntry struct {
+    name string
+    commit string
+    exclude []string
+}
+
+func makeDependencyPath(entries []pathEntry) *DependencyPath {
+    dp := &DependencyPath{}
+    for _, entry := range entries {
+        dp.PushExcluding(entry.name, entry.commit, entry.exclude)
+    }
+    return dp;
+}
+
+func TestDependencyPathFindCycle(t *testing.T) {
+    tests := []struct {
+        name string
+        path []pathEntry
+        module string
+        commit string
+        expected string
+    }{
+        {
+            name: "empty path",
+            module: "a", commit: "c1",
+        },
+        {
+            name: "no cycle",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1"}},
+            module: "b", commit: "c2",
+        },
+        {
+            name: "cycle to the root",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1"}},
+            module: "root", commit: "c2",
+            expected: "root → a@c1 → root@c2",
+        },
+        {
+            name: "cycle with a different commit",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1"}, {name: "b", commit: "c2"}},
+            module: "a", commit: "c3",
+            expected: "a@c1 → b@c2 → a@c3",
+        },
+        {
+            name: "module depends on itself",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1"}},
+            module: "a", commit: "c1",
+            expected: "a@c1 → a@c1",
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            dp := makeDependencyPath(test.path)
+            cycle := dp.FindCycle(test.module, test.commit)
+            if cycle != test.expected {
+                t.Errorf("Expected the cycle %q but got %q", test.expected, cycle)
+            }
+        })
+    }
+}
+
+func TestDependencyPathCheckCycle(t *testing.T) {
+    defer func(cycles string) { Options.Cycles = cycles }(Options.Cycles)
+    tests := []struct {
+        name string
+        cycles string
+        module string
+        skip bool
+        fails bool
+    }{
+        {name: "no cycle", cycles: "fail", module: "b"},
+        {name: "fail", cycles: "fail", module: "root", skip: true, fails: true},
+        {name: "break", cycles: "break", module: "root", skip: true},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            Options.Cycles = test.cycles
+            dp := makeDependencyPath([]pathEntry{{name: "root"}, {name: "a", commit: "c1"}})
+            skip, err := dp.CheckCycle(test.module, "c2")
+            if skip != test.skip {
+                t.Errorf("Expected skip to be %v", test.skip)
+            }
+            if (err != nil) != test.fails {
+                t.Errorf("Expected an error to be %v but got %v", test.fails, err)
+            }
+        })
+    }
+}
+
+func TestDependencyPathExcludedBy(t *testing.T) {
+    tests := []struct {
+        name string
+        path []pathEntry
+        module string
+        expected string
+        hasExcludes bool
+    }{
+        {
+            name: "nothing excluded",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1"}},
+            module: "x",
+        },
+        {
+            name: "excluded by the parent",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1", exclude: []string{"x"}}},
+            module: "x",
+            expected: "a@c1",
+            hasExcludes: true,
+        },
+        {
+            name: "excluded further up",
+            path: []pathEntry{{name: "root", exclude: []string{"x"}}, {name: "a", commit: "c1"}, {name: "b", commit: "c2"}},
+            module: "x",
+            expected: "root",
+            hasExcludes: true,
+        },
+        {
+            name: "the closest module that excludes",
+            path: []pathEntry{{name: "root", exclude: []string{"x"}}, {name: "a", commit: "c1", exclude: []string{"y", "x"}}},
+            module: "x",
+            expected: "a@c1",
+            hasExcludes: true,
+        },
+        {
+            name: "another module excluded",
+            path: []pathEntry{{name: "root"}, {name: "a", commit: "c1", exclude: []string{"y"}}},
+            module: "x",
+            hasExcludes: true,
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            dp := makeDependencyPath(test.path)
+            excludedBy := dp.ExcludedBy(test.module)
+            if excludedBy != test.expected {
+                t.Errorf("Expected %q but got %q", test.expected, excludedBy)
+            }
+            if dp.HasExcludes() != test.hasExcludes {
+                t.Errorf("Expected HasExcludes to be %v", test.hasExcludes)
+            }
+        })
+    }
+}
+
+func TestDependencyPathPop(t *testing.T) {
+    dp := makeDependencyPath([]pathEntry{{name: "root"}, {name: "a", commit: "c1", exclude: []string{"x"}}})
+    dp.Pop()
+    if dp.ExcludedBy("x") != "" || dp.HasExcludes() {
+        t.Errorf("Expected the exclude list to be removed with the module")
+    }
+    if dp.Last() != "root" || !dp.IsRoot() {
+        t.Errorf("Expected only the root to be left but got %v", dp.Names)
+    }
+    dp.Pop()
+    dp.Pop()
+    if !dp.IsEmpty() {
+        t.Errorf("Expected the path to be empty but got %v", dp.Names)
+    }
+}
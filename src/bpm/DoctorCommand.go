@@ -0,0 +1,205 @@
+package main;
+
+import (
+    "fmt"
+    "path"
+    "strings"
+    "io/ioutil"
+    "bpmerror"
+)
+
+// Checks the environment and the project for the common problems and prints a fix for each problem found
+type DoctorCommand struct {
+    Problems int
+    Warnings int
+}
+
+func (cmd *DoctorCommand) Name() string {
+    return "doctor"
+}
+
+func (cmd *DoctorCommand) ok(message string) {
+    fmt.Println("[ok]    " + message)
+}
+
+func (cmd *DoctorCommand) warn(message string, fix string) {
+    cmd.Warnings++
+    fmt.Println("[warn]  " + message)
+    fmt.Println("        Fix: " + fix)
+}
+
+func (cmd *DoctorCommand) fail(message string, fix string) {
+    cmd.Problems++
+    fmt.Println("[fail]  " + message)
+    fmt.Println("        Fix: " + fix)
+}
+
+func (cmd *DoctorCommand) checkTool(name string, required bool, fix string) {
+    rc := OsExec{Dir: Options.WorkingDir, LogOutput: true}
+    stdOut, err := rc.Run(name + " --version")
+    if err != nil {
+        if required {
+            cmd.fail(name + " was not found", fix)
+        } else {
+            cmd.warn(name + " was not found", fix)
+        }
+        return;
+    }
+    lines := strings.Split(strings.TrimSpace(stdOut), "\n")
+    cmd.ok(name + " " + strings.TrimSpace(lines[0]))
+}
+
+func (cmd *DoctorCommand) checkTools() {
+    cmd.checkTool("git", true, "Install git and make sure it is in the PATH")
+    for _, packageManager := range []string{"npm", "yarn", "pnpm"} {
+        cmd.checkTool(packageManager, packageManager == Options.PackageManager, "Install " + packageManager + " and make sure it is in the PATH")
+    }
+}
+
+func (cmd *DoctorCommand) checkRepository() {
+    git := GitExec{Path: Options.WorkingDir}
+    if !git.IsGitRepo() {
+        cmd.fail(Options.WorkingDir + " is not a git repository", "Run bpm in the root folder of a git repository or run git init")
+        return;
+    }
+    cmd.ok(Options.WorkingDir + " is a git repository")
+    if Options.UseRemoteUrl != "" {
+        cmd.ok("Using the remote url " + Options.UseRemoteUrl)
+        return;
+    }
+    if !git.HasRemote(Options.UseRemoteName) {
+        cmd.fail("The remote " + Options.UseRemoteName + " does not exist", "Add the remote with git remote add " + Options.UseRemoteName + " <url> or use the --remote= option")
+        return;
+    }
+    remoteUrl, err := git.GetRemoteUrl(Options.UseRemoteName)
+    if err != nil {
+        cmd.fail("The url of the remote " + Options.UseRemoteName + " could not be parsed", "The remote url must be an http(s) url that ends with .git. Change it with git remote set-url " + Options.UseRemoteName + " <url> or use the --remoteurl= option")
+        return;
+    }
+    cmd.ok("The remote " + Options.UseRemoteName + " is " + remoteUrl)
+}
+
+func (cmd *DoctorCommand) checkBpmFile() *BpmData {
+    err := Options.DoesBpmFileExist();
+    if err != nil {
+        cmd.fail("The " + Options.BpmFileName + " file does not exist", "Run bpm init <modulename> to create it")
+        return nil;
+    }
+    bpm := &BpmData{}
+    err = bpm.LoadFile(Options.BpmFileName)
+    if err == nil {
+        err = bpm.Validate()
+    }
+    if err != nil {
+        cmd.fail("The " + Options.BpmFileName + " file is not valid. " + err.Error(), "Correct the " + Options.BpmFileName + " file")
+        return nil;
+    }
+    valid := true
+    for _, name := range bpm.GetSortedKeys() {
+        dep := bpm.Dependencies[name]
+        err = dep.Validate()
+        if err != nil {
+            valid = false
+            cmd.fail("The dependency " + name + " is not valid. " + err.Error(), "Correct the entry for " + name + " in the " + Options.BpmFileName + " file")
+            continue;
+        }
+        if dep.Commit == Options.LocalModuleName {
+            valid = false
+            cmd.fail("The dependency " + name + " is pinned to '" + Options.LocalModuleName + "'", "Commit the changes in " + name + " and run bpm update " + name + " --root=<path>")
+        }
+    }
+    if valid {
+        cmd.ok("The " + Options.BpmFileName + " file is valid")
+    }
+    return bpm;
+}
+
+// Walk the dependencies in the bpm cache and report the modules that are missing. The same rules as the install are
+// used, so the overrides and exclusions apply and only the dev dependencies of the root project are checked. A module
+// below an exclusion is checked again for the other parents since the excluded modules are not checked below it.
+func (cmd *DoctorCommand) checkModules(bpm *BpmData, dp *DependencyPath, visited map[string]bool) bool {
+    complete := true
+    for _, name := range bpm.GetInstallKeys(dp.IsRoot()) {
+        if dp.ExcludedBy(name) != "" {
+            continue;
+        }
+        dep, _ := dependencyOverrides.Apply(name, dp, bpm.Dependencies[name])
+        key := name + "@" + dep.Commit
+        if excluding, exists := visited[key]; exists && !excluding {
+            continue;
+        }
+        visited[key] = dp.HasExcludes() || len(dep.Exclude) > 0 || dependencyOverrides.HasParents()
+        modulePath := path.Join(Options.BpmCachePath, name, dep.Commit)
+        if PathExists(path.Join(Options.BpmCachePath, name, Options.LocalModuleName)) {
+            modulePath = path.Join(Options.BpmCachePath, name, Options.LocalModuleName)
+        } else if !PathExists(modulePath) && dep.IsOptional() {
+            cmd.warn("The optional module " + name + " @ " + dep.Commit + " required by " + dp.Last() + " is not in " + Options.BpmCachePath, "Run bpm install if the module should be installed")
+            continue;
+        } else if !PathExists(modulePath) {
+            complete = false
+            cmd.fail("The module " + name + " @ " + dep.Commit + " required by " + dp.Last() + " is missing from " + Options.BpmCachePath, "Run bpm install")
+            continue;
+        }
+        moduleBpm := &BpmData{}
+        err := moduleBpm.LoadDependencyFile(path.Join(modulePath, Options.BpmFileName))
+        if err != nil || dp.FindCycle(moduleBpm.Name, dep.Commit) != "" {
+            continue;
+        }
+        dp.PushExcluding(moduleBpm.Name, dep.Commit, dep.Exclude)
+        if !cmd.checkModules(moduleBpm, dp, visited) {
+            complete = false
+        }
+        dp.Pop()
+    }
+    return complete;
+}
+
+func (cmd *DoctorCommand) checkCache() {
+    if !PathExists(Options.BpmCachePath) {
+        cmd.warn("The " + Options.BpmCachePath + " folder does not exist", "Run bpm install")
+        return;
+    }
+    entries, _ := ioutil.ReadDir(Options.BpmCachePath)
+    clean := true
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            continue;
+        }
+        if entry.Name() == "xx_temp_xx" {
+            clean = false
+            cmd.warn("A temporary folder from an interrupted bpm command was found in " + Options.BpmCachePath, "Delete the folder " + path.Join(Options.BpmCachePath, entry.Name()))
+            continue;
+        }
+        localPath := path.Join(Options.BpmCachePath, entry.Name(), Options.LocalModuleName)
+        if PathExists(localPath) && Options.UseLocalPath == "" {
+            clean = false
+            cmd.warn("The local folder " + localPath + " overrides the commit in the " + Options.BpmFileName + " file", "Delete the folder " + localPath + " if you are no longer working on " + entry.Name() + " locally")
+        }
+    }
+    if clean {
+        cmd.ok("The " + Options.BpmCachePath + " folder has no temporary or local folders")
+    }
+}
+
+func (cmd *DoctorCommand) Execute() (error) {
+    fmt.Println("")
+    cmd.checkTools()
+    cmd.checkRepository()
+    bpm := cmd.checkBpmFile()
+    cmd.checkCache()
+    if bpm != nil && PathExists(Options.BpmCachePath) {
+        dependencyOverrides.Load(bpm)
+        dp := &DependencyPath{}
+        dp.Push(bpm.Name, "")
+        if cmd.checkModules(bpm, dp, make(map[string]bool)) {
+            cmd.ok("The " + Options.BpmCachePath + " folder matches the " + Options.BpmFileName + " file")
+        }
+    }
+    fmt.Println("")
+    fmt.Println(cmd.Problems, "problems,", cmd.Warnings, "warnings")
+    fmt.Println("")
+    if cmd.Problems > 0 {
+        return bpmerror.New(nil, "Error: bpm doctor found problems")
+    }
+    return nil;
+}
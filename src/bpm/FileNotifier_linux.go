@@ -0,0 +1,65 @@
+package main;
+
+import (
+    "syscall"
+)
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_DELETE_SELF | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// Notifies when something changes in the watched folders using inotify. The events only tell that something changed
+// and the caller finds out what changed by comparing the files.
+type FileNotifier struct {
+    fd int
+    watches map[string]bool
+    events chan bool
+}
+
+func NewFileNotifier() (*FileNotifier, error) {
+    fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+    if err != nil {
+        return nil, err;
+    }
+    notifier := &FileNotifier{fd: fd, watches: make(map[string]bool), events: make(chan bool, 1)}
+    go notifier.read()
+    return notifier, nil;
+}
+
+func (notifier *FileNotifier) read() {
+    buffer := make([]byte, syscall.SizeofInotifyEvent * 256)
+    for {
+        n, err := syscall.Read(notifier.fd, buffer)
+        if err != nil || n <= 0 {
+            return;
+        }
+        select {
+        case notifier.events <- true:
+        default:
+        }
+    }
+}
+
+// Watch a folder. Folders that are already watched are ignored.
+func (notifier *FileNotifier) Add(folder string) error {
+    if notifier.watches[folder] {
+        return nil;
+    }
+    _, err := syscall.InotifyAddWatch(notifier.fd, folder, inotifyMask)
+    if err != nil {
+        return err;
+    }
+    notifier.watches[folder] = true
+    return nil;
+}
+
+// Forget a folder that was deleted. The kernel removes the watch of a deleted folder on its own.
+func (notifier *FileNotifier) Remove(folder string) {
+    delete(notifier.watches, folder)
+}
+
+func (notifier *FileNotifier) Events() <-chan bool {
+    return notifier.events;
+}
+
+func (notifier *FileNotifier) Close() {
+    syscall.Close(notifier.fd)
+}
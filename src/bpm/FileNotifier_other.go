@@ -0,0 +1,29 @@
+// +build !linux
+
+package main;
+
+import (
+    "errors"
+)
+
+// File notifications are only supported on linux. On the other platforms the folders are polled.
+type FileNotifier struct {
+}
+
+func NewFileNotifier() (*FileNotifier, error) {
+    return nil, errors.New("File notifications are not supported on this platform");
+}
+
+func (notifier *FileNotifier) Add(folder string) error {
+    return nil;
+}
+
+func (notifier *FileNotifier) Remove(folder string) {
+}
+
+func (notifier *FileNotifier) Events() <-chan bool {
+    return nil;
+}
+
+func (notifier *FileNotifier) Close() {
+}
@@ -2,9 +2,10 @@ package main;
 
 import (
     "strings"
-    "fmt"
+    "path"
     "regexp"
     "errors"
+    "bpmerror"
 )
 
 type GitExec struct {
@@ -13,7 +14,21 @@ type GitExec struct {
 
 
 func (git *GitExec) IsGitRepo() bool {
-    return PathExists(".git")
+    return PathExists(path.Join(git.Path, ".git"))
+}
+
+func (git *GitExec) HasRemote(remoteName string) bool {
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    stdOut, err := rc.Run("git remote")
+    if err != nil {
+        return false;
+    }
+    for _, name := range strings.Split(stdOut, "\n") {
+        if strings.TrimSpace(name) == remoteName {
+            return true;
+        }
+    }
+    return false;
 }
 
 func (git *GitExec) HasChanges() bool {
@@ -65,10 +80,85 @@ func (git *GitExec) GetRemoteUrl(remoteName string) (string, error) {
     if len(matched) == 0 {
         return "", errors.New("Could not find the remote " + remoteName)
     }
-    if len(matched[1]) == 0 {
+    if len(matched[0]) < 2 {
         return "", errors.New("Could not find the remote " + remoteName)
     }
-    return matched[1][1], nil;
+    return matched[0][1], nil;
+}
+
+// Determine the default branch of the remote repository by reading the symbolic ref of the remote HEAD
+//"git ls-remote --symref <url> HEAD" prints "ref: refs/heads/<branch>	HEAD"
+func (git *GitExec) GetDefaultBranch(url string) (string, error) {
+    gitCommand := "git ls-remote --symref " + url + " HEAD"
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    stdOut, err := rc.Run(gitCommand)
+    if err != nil {
+        return "", bpmerror.NewKind(bpmerror.Network, err, "Error: Could not read the remote " + url)
+    }
+    branch := parseDefaultBranch(stdOut)
+    if branch == "" {
+        return "", errors.New("Could not determine the default branch of " + url)
+    }
+    return branch, nil;
+}
+
+// Returns the branch of the symbolic ref in the ls-remote output, or an empty string if there is none
+func parseDefaultBranch(stdOut string) string {
+    re := regexp.MustCompile("ref:\\s+refs/heads/(\\S+)\\s+HEAD")
+    matched := re.FindStringSubmatch(stdOut)
+    if len(matched) < 2 {
+        return "";
+    }
+    return matched[1];
+}
+
+// Returns true if there are changes to the tracked files or untracked files that are not ignored
+func (git *GitExec) HasUncommittedFiles() (bool, error) {
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    stdOut, err := rc.Run("git status --porcelain")
+    if err != nil {
+        return false, err;
+    }
+    return strings.TrimSpace(stdOut) != "", nil;
+}
+
+// Returns the tracked files that changed since the last commit, staged or not
+func (git *GitExec) GetChangedFiles() ([]string, error) {
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    stdOut, err := rc.Run("git diff-index --name-only HEAD --")
+    if err != nil {
+        return nil, err;
+    }
+    files := []string{}
+    for _, file := range strings.Split(stdOut, "\n") {
+        if strings.TrimSpace(file) != "" {
+            files = append(files, strings.TrimSpace(file))
+        }
+    }
+    return files, nil;
+}
+
+func (git *GitExec) TagExists(tag string) bool {
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    _, err := rc.RunArgs([]string{"git", "rev-parse", "--verify", "--quiet", "refs/tags/" + tag})
+    return err == nil;
+}
+
+func (git *GitExec) Commit(message string, files []string) error {
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    _, err := rc.RunArgs(append([]string{"git", "add", "--"}, files...))
+    if err != nil {
+        return err;
+    }
+    _, err = rc.RunArgs([]string{"git", "commit", "-m", message})
+    return err;
+}
+
+// Create an annotated tag on the current commit
+func (git *GitExec) Tag(tag string, message string) error {
+    rc := OsExec{Dir: git.Path, LogOutput: true}
+    _, err := rc.RunArgs([]string{"git", "tag", "-a", tag, "-m", message})
+    return err;
 }
 
 func (git *GitExec) GetLatestCommit() (string, error) {
@@ -81,8 +171,15 @@ func (git *GitExec) GetLatestCommit() (string, error) {
     return stdOut, nil;
 }
 
+// Get the contents of a file at the specified commit without checking it out
+func (git *GitExec) ShowFile(commit string, file string) (string, error) {
+    gitCommand := "git show " + commit + ":" + file
+    rc := OsExec{Dir: git.Path, LogOutput: false}
+    return rc.Run(gitCommand)
+}
+
 func (git *GitExec) Init() error {
-    fmt.Println("Initializing empty git repository")
+    Log.Debug("Initializing empty git repository")
     gitCommand := "git init";
     rc := OsExec{Dir: git.Path, LogOutput: true}
     _, err := rc.Run(gitCommand);
@@ -90,7 +187,7 @@ func (git *GitExec) Init() error {
 }
 
 func (git *GitExec) AddRemote(name string, url string) error {
-    fmt.Println("Adding remote", url, "as", name)
+    Log.Debug("Adding remote", url, "as", name)
     gitCommand := "git remote add " + name + " " + url
     rc := OsExec{Dir: git.Path, LogOutput: true}
     _, err := rc.Run(gitCommand)
@@ -106,15 +203,18 @@ func (git *GitExec) InitAndFetch(url string) error {
     if err != nil {
         return err;
     }
-    fmt.Println("Fetching...")
+    Log.Info("Fetching", url, "...")
     gitCommand := "git fetch --all"
     rc := OsExec{Dir: git.Path, LogOutput: true}
     _, err = rc.Run(gitCommand);
-    return err
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.Network, err, "Error: Failed to fetch " + url)
+    }
+    return nil
 }
 
 func (git *GitExec) Checkout(commit string) (error) {
-    fmt.Println("Checking out commit", commit)
+    Log.Info("Checking out commit", commit)
     gitCommand := "git checkout " + commit
     rc := OsExec{Dir: git.Path, LogOutput: true}
     _, err := rc.Run(gitCommand);
@@ -122,7 +222,7 @@ func (git *GitExec) Checkout(commit string) (error) {
 }
 
 func (git *GitExec) SubmoduleUpdate(init bool, recursive bool) (error) {
-    fmt.Println("Updating submodules...")
+    Log.Debug("Updating submodules...")
     gitCommand := "git submodule update ";
     if init {
         gitCommand = gitCommand + "--init "
@@ -142,13 +242,17 @@ func (git *GitExec) InitAndCheckout(url string, commit string) error {
     }
     err = git.Checkout(commit)
     if err != nil {
-        return err;
+        return bpmerror.NewKind(bpmerror.CommitNotFound, err, "Error: Could not checkout " + commit + " from " + url)
+    }
+    err = git.SubmoduleUpdate(true, true)
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.Network, err, "Error: Failed to update the submodules of " + url)
     }
-    return git.SubmoduleUpdate(true, true)
+    return nil
 }
 
 func (git *GitExec) Clone(url string, commit string) error {
-    fmt.Println("Cloning", url, "...")
+    Log.Info("Cloning", url, "...")
     // git clone <repo url> <destination directory>
     gitCommand := "git clone " + url + " " + commit
     rc := OsExec{Dir: git.Path, LogOutput: true}
@@ -0,0 +1,113 @@
+package main;
+
+import (
+    "os"
+    "os/exec"
+    "strings"
+    "testing"
+    "io/ioutil"
+    "path/filepath"
+)
+
+// Run git in the folder with a fixed identity so the tests do not depend on the git config of the machine
+func runGit(t *testing.T, dir string, args ...string) string {
+    args = append([]string{"-c", "user.name=bpm", "-c", "user.email=bpm@example.com", "-c", "commit.gpgsign=false"}, args...)
+    cmd := exec.Command("git", args...)
+    cmd.Dir = dir
+    out, err := cmd.CombinedOutput()
+    if err != nil {
+        t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+    }
+    return strings.TrimSpace(string(out));
+}
+
+// Write the files to the repository, creating it if needed, and commit them. Returns the commit hash.
+func commitFiles(t *testing.T, dir string, files map[string]string) string {
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git is not installed")
+    }
+    if !PathExists(filepath.Join(dir, ".git")) {
+        os.MkdirAll(dir, 0777)
+        runGit(t, dir, "init", "-q")
+    }
+    for name, content := range files {
+        file := filepath.Join(dir, filepath.FromSlash(name))
+        os.MkdirAll(filepath.Dir(file), 0777)
+        err := ioutil.WriteFile(file, []byte(content), 0666)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    runGit(t, dir, "add", "-A")
+    runGit(t, dir, "commit", "-q", "--allow-empty", "-m", "commit")
+    return runGit(t, dir, "rev-parse", "HEAD");
+}
+
+func makeTempDir(t *testing.T) string {
+    temp, err := ioutil.TempDir("", "bpm-test")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The temp folder may be a link, for example on darwin, so the real path is used
+    temp, err = filepath.EvalSymlinks(temp)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return temp;
+}
+
+func TestParseDefaultBranch(t *testing.T) {
+    tests := []struct {
+        name string
+        output string
+        expected string
+    }{
+        {name: "master", output: "ref: refs/heads/master\tHEAD\n0123456789abcdef0123456789abcdef01234567\tHEAD\n", expected: "master"},
+        {name: "main", output: "ref: refs/heads/main\tHEAD\n0123456789abcdef0123456789abcdef01234567\tHEAD\n", expected: "main"},
+        {name: "slash", output: "ref: refs/heads/release/2.x\tHEAD\n0123456789abcdef0123456789abcdef01234567\tHEAD\n", expected: "release/2.x"},
+        {name: "detached head", output: "0123456789abcdef0123456789abcdef01234567\tHEAD\n"},
+        {name: "empty repository", output: ""},
+        {name: "other ref", output: "ref: refs/heads/main\trefs/remotes/origin/HEAD\n"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            branch := parseDefaultBranch(test.output)
+            if branch != test.expected {
+                t.Errorf("Expected the branch %q but got %q", test.expected, branch)
+            }
+        })
+    }
+}
+
+func TestGetDefaultBranch(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    tests := []struct {
+        name string
+        branch string
+    }{
+        {name: "master", branch: "master"},
+        {name: "develop", branch: "develop"},
+        {name: "slash", branch: "release/2.x"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            repo := filepath.Join(temp, test.name)
+            commitFiles(t, repo, map[string]string{"bpm.json": "{}"})
+            runGit(t, repo, "checkout", "-q", "-B", test.branch)
+            git := GitExec{Path: temp}
+            branch, err := git.GetDefaultBranch("file://" + repo)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if branch != test.branch {
+                t.Errorf("Expected the branch %q but got %q", test.branch, branch)
+            }
+        })
+    }
+    git := GitExec{Path: temp}
+    _, err := git.GetDefaultBranch("file://" + filepath.Join(temp, "missing"))
+    if err == nil {
+        t.Errorf("Expected an error for a missing repository")
+    }
+}
@@ -39,6 +39,9 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("        # install and save the mortar dependency using the remote brandon as root path and the latest commit.")
     fmt.Println("        bpm install ../mortar.git --remote=brandon");
     fmt.Println("");
+    fmt.Println("        # install and save the mortar dependency using the latest commit of the develop branch.")
+    fmt.Println("        bpm install ../mortar.git#develop");
+    fmt.Println("");
     fmt.Println("        # install and save the mortar dependency using the specified url and the latest commit.")
     fmt.Println("        bpm install https://neudesic.timu.com/projects/timu/code/master/mortar.git");
     fmt.Println("");
@@ -46,9 +49,12 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("        # ignores commit information")
     fmt.Println("        bpm install --root=../js");
     fmt.Println("");
+    fmt.Println("        # install the dependencies without the devDependencies of the bpm.json file")
+    fmt.Println("        bpm install --production");
+    fmt.Println("");
     fmt.Println("    update")
     fmt.Println("")
-    fmt.Println("        bpm update [dependencyName] ( [--remote=myremote] | [--root=diskpath] ) [--recursive]");
+    fmt.Println("        bpm update [dependencyName] ( [--remote=myremote] | [--root=diskpath] [--link] ) [--recursive [--commit [--tag]]]");
     fmt.Println("");
     fmt.Println("        Examples:")
     fmt.Println("");
@@ -61,6 +67,9 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("        # update the existing mortar dependency to the latest commit. Use the remote origin as the root path if necessary.")
     fmt.Println("        bpm update mortar");
     fmt.Println("");
+    fmt.Println("        # update the existing mortar dependency to the latest commit of the release branch.")
+    fmt.Println("        bpm update mortar --branch=release");
+    fmt.Println("");
     fmt.Println("        # update the existing mortar dependency to the latest commit. Use the remote brandon as the root path if necessary.")
     fmt.Println("        bpm update mortar --remote=brandon");
     fmt.Println("");
@@ -69,6 +78,13 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("");
     fmt.Println("        # update all dependencies recursively. Only works with the --root option")
     fmt.Println("        bpm update mortar --root=../js --recursive");
+    fmt.Println("");
+    fmt.Println("        # update all dependencies recursively and commit the bpm.json of each local module before its parent is pinned to it,")
+    fmt.Println("        # from the bottom of the graph up. --tag also tags each commit with v<version>")
+    fmt.Println("        bpm update --root=../js --recursive --commit --tag");
+    fmt.Println("");
+    fmt.Println("        # link the local dependencies instead of copying them, so changes in ../js are used without another update")
+    fmt.Println("        bpm update --root=../js --link");
     fmt.Println("")
     fmt.Println("    uninstall")
     fmt.Println("")
@@ -90,22 +106,118 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("")
     fmt.Println("    clean")
     fmt.Println("")
-    fmt.Println("        bpm clean");
+    fmt.Println("        bpm clean [--trim] [--keep-local] [--dry-run] [--yes]");
     fmt.Println("");
     fmt.Println("        Examples:")
     fmt.Println("");
-    fmt.Println("        # delete the bpm_modules folder")
+    fmt.Println("        # delete the bpm_modules folder. bpm asks for confirmation first")
     fmt.Println("        bpm clean");
+    fmt.Println("");
+    fmt.Println("        # delete the bpm_modules folder without asking for confirmation")
+    fmt.Println("        bpm clean --yes");
+    fmt.Println("");
+    fmt.Println("        # delete the modules in the bpm_modules folder that are no longer required by the bpm.json file")
+    fmt.Println("        bpm clean --trim");
+    fmt.Println("");
+    fmt.Println("        # show what would be deleted and how much space would be reclaimed without deleting anything")
+    fmt.Println("        bpm clean --trim --dry-run");
+    fmt.Println("");
+    fmt.Println("        # keep the local folders and the modules they require")
+    fmt.Println("        bpm clean --trim --keep-local");
     fmt.Println("")
     fmt.Println("    ls")
     fmt.Println("")
-    fmt.Println("        bpm ls");
+    fmt.Println("        bpm ls [moduleName] [--depth=N]");
     fmt.Println("");
     fmt.Println("        Examples:")
     fmt.Println("");
     fmt.Println("        # list the installed dependencies")
     fmt.Println("        bpm ls");
     fmt.Println("");
+    fmt.Println("        # only list the direct dependencies")
+    fmt.Println("        bpm ls --depth=0");
+    fmt.Println("");
+    fmt.Println("        # only list the dependencies that lead to my-dependency-1")
+    fmt.Println("        bpm ls my-dependency-1");
+    fmt.Println("");
+    fmt.Println("    doctor")
+    fmt.Println("")
+    fmt.Println("        bpm doctor");
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("");
+    fmt.Println("        # check the tools, the git repository, the bpm.json and the bpm_modules folder and print a fix for each problem")
+    fmt.Println("        bpm doctor");
+    fmt.Println("");
+    fmt.Println("    prune")
+    fmt.Println("")
+    fmt.Println("        bpm prune [--dry-run] [--pkgm=yarn]");
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("");
+    fmt.Println("        # remove the packages bpm installed to node_modules that are no longer required by the bpm.json file")
+    fmt.Println("        bpm prune");
+    fmt.Println("");
+    fmt.Println("        # show the packages that would be removed without removing anything")
+    fmt.Println("        bpm prune --dry-run");
+    fmt.Println("");
+    fmt.Println("    release")
+    fmt.Println("")
+    fmt.Println("        bpm release [patch|minor|major] [--dry-run]");
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("");
+    fmt.Println("        # increment the patch version in the bpm.json and package.json, commit the change and tag the commit with v<version>")
+    fmt.Println("        bpm release");
+    fmt.Println("");
+    fmt.Println("        # show the version and the tag of a minor release without changing anything")
+    fmt.Println("        bpm release minor --dry-run");
+    fmt.Println("");
+    fmt.Println("    vendor")
+    fmt.Println("")
+    fmt.Println("        bpm vendor [folder | file.tar.gz]");
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("");
+    fmt.Println("        # export every module in the dependency graph at its commit and a manifest of the graph to the bpm_vendor folder")
+    fmt.Println("        bpm vendor");
+    fmt.Println("");
+    fmt.Println("        # export the modules to a tarball");
+    fmt.Println("        bpm vendor vendor.tar.gz");
+    fmt.Println("");
+    fmt.Println("        # install the dependencies from the tarball without access to the git repositories");
+    fmt.Println("        bpm install --from-vendor=vendor.tar.gz");
+    fmt.Println("");
+    fmt.Println("    watch")
+    fmt.Println("")
+    fmt.Println("        bpm watch --root=diskpath [--exec=command] [--poll] [--interval=1s]");
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("");
+    fmt.Println("        # copy the changed files of the local modules in ../js to bpm_modules and node_modules as they change")
+    fmt.Println("        bpm watch --root=../js");
+    fmt.Println("");
+    fmt.Println("        # run a command after each sync")
+    fmt.Println("        bpm watch --root=../js --exec=\"npm run build\"");
+    fmt.Println("");
+    fmt.Println("        # check the folders for changes every 2 seconds instead of using file notifications")
+    fmt.Println("        bpm watch --root=../js --poll --interval=2s");
+    fmt.Println("");
+    fmt.Println("    rewrite-urls")
+    fmt.Println("")
+    fmt.Println("        bpm rewrite-urls [<match>=<replace> ...] [--root=diskpath] [--commit] [--dry-run] [--yes]");
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("");
+    fmt.Println("        # replace the start of the dependency urls in the bpm.json. bpm shows the changes and asks for confirmation first")
+    fmt.Println("        bpm rewrite-urls https://neudesic.timu.com/projects/timu/code/master/=../");
+    fmt.Println("");
+    fmt.Println("        # rewrite the urls in the bpm.json of every local module in ../js as well and commit the change in each repository")
+    fmt.Println("        bpm rewrite-urls https://old.host/=https://new.host/ --root=../js --commit");
+    fmt.Println("");
+    fmt.Println("        # show the changes of the rewrite rules in the .bpmrc file without changing anything")
+    fmt.Println("        bpm rewrite-urls --dry-run");
+    fmt.Println("");
     fmt.Println("    help")
     fmt.Println("")
     fmt.Println("        bpm help");
@@ -121,6 +233,71 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("")
     fmt.Println("        bpm install --remoteurl=https://neudesic.timu.com/myproject.git")
     fmt.Println("")
+    fmt.Println("    --branch=");
+    fmt.Println("");
+    fmt.Println("        This flag will cause bpm to use the latest commit of the specified branch when installing a new dependency or updating a dependency.")
+    fmt.Println("        By default the branch in the bpm.json is used, or the default branch of the remote if there is none.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm update --branch=main")
+    fmt.Println("")
+    fmt.Println("    --bump=");
+    fmt.Println("");
+    fmt.Println("        The version increment used when the dependencies in a bpm.json change. One of none, patch, minor, major or auto. By default patch is used.")
+    fmt.Println("        auto will increment the minor version when the major version of a dependency changed and the patch version otherwise.")
+    fmt.Println("        The default can be set with the bump field in the .bpmrc file.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm update --bump=none")
+    fmt.Println("        bpm install ../mortar.git --bump=minor")
+    fmt.Println("")
+    fmt.Println("    --ignore-scripts");
+    fmt.Println("");
+    fmt.Println("        This flag will cause bpm to skip the scripts in the scripts section of the bpm.json files")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm install --ignore-scripts")
+    fmt.Println("")
+    fmt.Println("    --cycles=");
+    fmt.Println("");
+    fmt.Println("        What to do when the dependencies contain a cycle. fail stops with an error and break ignores the dependency that closes the cycle.")
+    fmt.Println("        By default fail is used. The default can be set with the cycles field in the .bpmrc file.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm install --cycles=break")
+    fmt.Println("")
+    fmt.Println("    --retries=");
+    fmt.Println("");
+    fmt.Println("        The number of times a fetch that fails with a network error is tried again before the next mirror is tried. The delay starts")
+    fmt.Println("        at one second and doubles after each attempt. By default 2 is used. The default can be set with the retries field in the .bpmrc file.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm install --retries=5")
+    fmt.Println("        bpm update --retries=0")
+    fmt.Println("")
+    fmt.Println("    --quiet | --verbose | --log-level=");
+    fmt.Println("");
+    fmt.Println("        Controls how much bpm logs. The levels are error, warn, info, debug and trace. By default info is used.")
+    fmt.Println("        --quiet only logs errors and warnings and --verbose logs debug messages including the git and npm commands.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm install --quiet")
+    fmt.Println("        bpm install --log-level=trace")
+    fmt.Println("")
+    fmt.Println("    --log-format=");
+    fmt.Println("");
+    fmt.Println("        The format of the log. One of text or json. The json format writes one event per line with the level, module, phase and duration.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm install --log-format=json")
+    fmt.Println("")
     fmt.Println("    --skipnpm");
     fmt.Println("");
     fmt.Println("        This flag will cause bpm to skip the package manager install phase")
@@ -139,6 +316,42 @@ func (help *HelpCommand) Execute() (error){
     fmt.Println("        bpm --pgkm=npm")
     fmt.Println("        bpm --pkgm=yarn [--yarn-packages-root=] [--yarn-modules-folder=]")
     fmt.Println("")
+    fmt.Println("    --exclude=");
+    fmt.Println("");
+    fmt.Println("        A pipe separated list of glob patterns for the files that are not copied from the local modules, in addition to")
+    fmt.Println("        .git, .gitignore, .gitmodules, bpm_modules and node_modules. The patterns can also be set with the exclude field in")
+    fmt.Println("        the .bpmrc file, and each module can have a .bpmignore file that uses the gitignore syntax.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm update --root=../js --exclude=\"*.log|test/fixtures/\"")
+    fmt.Println("")
+    fmt.Println("    --checksum");
+    fmt.Println("");
+    fmt.Println("        The local modules are synced to bpm_modules and node_modules, and only the files with a different size or modification time")
+    fmt.Println("        are copied. With --checksum the contents of the other files are compared as well.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm update --root=../js --checksum")
+    fmt.Println("")
+    fmt.Println("    --install-mode=");
+    fmt.Println("");
+    fmt.Println("        How the modules are installed to node_modules. each runs the package manager install once for each module.")
+    fmt.Println("        package-json writes a file: reference to the bpm_modules folder for each module to the dependencies in the package.json")
+    fmt.Println("        and runs one package manager install, so the lockfile includes the modules. By default each is used.")
+    fmt.Println("        The default can be set with the installMode field in the .bpmrc file.")
+    fmt.Println("");
+    fmt.Println("        Examples:")
+    fmt.Println("")
+    fmt.Println("        bpm install --install-mode=package-json")
+    fmt.Println("")
+    fmt.Println("Exit Codes")
+    fmt.Println("");
+    fmt.Println("    0 success, 1 general error, 2 usage error, 3 invalid bpm.json, 4 network error, 5 commit not found,")
+    fmt.Println("    6 dependency conflict, 7 package manager failed, 8 local commit not finalized,")
+    fmt.Println("    9 uncommitted changes or an existing tag")
+    fmt.Println("")
 
 
     return nil;
@@ -2,7 +2,6 @@ package main;
 
 import (
     "os"
-    "fmt"
     "strings"
     "path"
     "bpmerror"
@@ -17,6 +16,13 @@ func (cmd *InstallCommand) Name() string {
 }
 
 func (cmd *InstallCommand) installNew(moduleUrl string, moduleCommit string) (error) {
+    moduleUrl, moduleBranch := SplitUrlBranch(moduleUrl)
+    if Options.UseBranch != "" {
+        moduleBranch = Options.UseBranch;
+    }
+    if Options.FromVendor != "" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The --from-vendor option can only be used to install the dependencies in the bpm.json file")
+    }
     bpm := BpmData{};
     err := Options.DoesBpmFileExist();
     if err != nil {
@@ -24,47 +30,67 @@ func (cmd *InstallCommand) installNew(moduleUrl string, moduleCommit string) (er
     }
     err = bpm.LoadFile(Options.BpmFileName);
     if err != nil {
-        return bpmerror.New(err, "Error: There was a problem loading the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
     }
     Options.EnsureBpmCacheFolder();
+    dependencyOverrides.Load(&bpm)
+    err = RunRootScript(&bpm, "preinstall")
+    if err != nil {
+        return err;
+    }
 
     itemRemoteUrl, err := MakeRemoteUrl(moduleUrl);
     if err != nil {
         return err;
     }
     var moduleBpm *BpmData;
-    if len(strings.TrimSpace(moduleCommit)) == 0 {
-        moduleCommit = "master";
-    }
-    moduleBpm, cacheItem, err := ProcessRemoteModule(itemRemoteUrl, moduleCommit)
+    moduleBpm, cacheItem, err := ProcessRemoteModule(itemRemoteUrl, nil, strings.TrimSpace(moduleCommit), moduleBranch)
     if err != nil {
         return err;
     }
     moduleCache.AddLatest(cacheItem)
+    // The new module is a dependency of the root project, so its dev dependencies are not installed
+    dependencyPath.Push(bpm.Name, "")
+    dependencyPath.Push(moduleBpm.Name, cacheItem.Commit)
     err = ProcessDependencies(moduleBpm, itemRemoteUrl, nil)
+    dependencyPath.Pop()
+    dependencyPath.Pop()
     if err != nil {
         return err;
     }
+    dependencyGraph.LogSummary();
+    dependencyGraph.WarnExcluded();
     moduleCache.Trim();
-    if !Options.SkipNpmInstall{
-        err := moduleCache.Install()
-        if err != nil {
-            return err;
-        }
+    err = moduleCache.InstallAndRunScripts()
+    if err != nil {
+        return err;
     }
 
-    newItem := &BpmDependency{Url:moduleUrl, Commit:cacheItem.Commit};
+    bump := NewVersionBump(&bpm)
+    newItem := &BpmDependency{Url:moduleUrl, Commit:cacheItem.Commit, Branch:moduleBranch};
+    // A module that is installed again stays in its group
+    if existingItem, exists := bpm.Dependencies[moduleBpm.Name]; exists {
+        newItem.Group = existingItem.Group
+    }
     bpm.Dependencies[moduleBpm.Name] = newItem;
 
-    err = bpm.IncrementVersion();
+    changed, err := bump.Apply(&bpm);
     if err != nil {
         return err;
     }
-    err = bpm.WriteFile(path.Join(Options.WorkingDir, Options.BpmFileName))
-    if err != nil {
-        return err;
+    if changed {
+        err = bpm.WriteFile(path.Join(Options.WorkingDir, Options.BpmFileName))
+        if err != nil {
+            return err;
+        }
+    }
+    if !Options.SkipNpmInstall {
+        err = PruneNodeModules(&bpm)
+        if err != nil {
+            return err;
+        }
     }
-    return nil;
+    return RunRootScript(&bpm, "postinstall");
 }
 
 func (cmd *InstallCommand) build(installItem string) (error) {
@@ -72,36 +98,52 @@ func (cmd *InstallCommand) build(installItem string) (error) {
     if err != nil {
         return err;
     }
-    fmt.Println("Reading",Options.BpmFileName,"...")
+    Log.Debug("Reading",Options.BpmFileName,"...")
     bpm := BpmData{}
     err = bpm.LoadFile(Options.BpmFileName);
     if err != nil {
-        return bpmerror.New(err, "Error: There was a problem loading the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
     }
-    fmt.Println("Validating bpm.json")
+    Log.Debug("Validating bpm.json")
     err = bpm.Validate();
     if err != nil {
         return err;
     }
+    err = RunRootScript(&bpm, "preinstall")
+    if err != nil {
+        return err;
+    }
     if !bpm.HasDependencies() {
-        fmt.Println("There are no dependencies")
-        return nil;
+        Log.Info("There are no dependencies")
+        return RunRootScript(&bpm, "postinstall");
     }
     Options.EnsureBpmCacheFolder();
+    closeVendor, err := OpenVendor()
+    if err != nil {
+        return err;
+    }
+    defer closeVendor()
+    dependencyOverrides.Load(&bpm)
     newBpm := bpm.Clone(installItem);
-    fmt.Println("Processing all dependencies for", bpm.Name, "version", bpm.Version);
+    Log.Info("Processing all dependencies for", bpm.Name, "version", bpm.Version);
     err = ProcessDependencies(newBpm, "", nil)
     if err != nil {
         return err;
     }
+    dependencyGraph.LogSummary();
+    dependencyGraph.WarnExcluded();
     moduleCache.Trim();
+    err = moduleCache.InstallAndRunScripts()
+    if err != nil {
+        return err;
+    }
     if !Options.SkipNpmInstall {
-        err = moduleCache.Install()
+        err = PruneNodeModules(&bpm)
         if err != nil {
             return err;
         }
     }
-    return nil;
+    return RunRootScript(&bpm, "postinstall");
 }
 
 func (cmd *InstallCommand) Execute() (error) {
@@ -115,7 +157,8 @@ func (cmd *InstallCommand) Execute() (error) {
         }
     }
 
-    if installItem != "" && newCommit != "" || strings.HasSuffix(installItem, ".git") {
+    installUrl, _ := SplitUrlBranch(installItem)
+    if installItem != "" && newCommit != "" || strings.HasSuffix(installUrl, ".git") {
         return cmd.installNew(installItem, newCommit);
     }
     return cmd.build(installItem);
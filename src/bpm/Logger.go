@@ -0,0 +1,134 @@
+package main;
+
+import (
+    "os"
+    "io"
+    "fmt"
+    "time"
+    "strings"
+    "encoding/json"
+)
+
+const (
+    LogLevelError = iota
+    LogLevelWarn
+    LogLevelInfo
+    LogLevelDebug
+    LogLevelTrace
+)
+
+var logLevelNames = []string{"error", "warn", "info", "debug", "trace"}
+
+// Writes the bpm log messages. In the text format the messages are written as is. In the json format every message is
+// written as one json object per line which contains the level and, when known, the module, phase and duration.
+type Logger struct {
+    Level int
+    Format string
+    Output io.Writer
+}
+
+type LogEvent struct {
+    Time string `json:"time"`
+    Level string `json:"level"`
+    Module string `json:"module,omitempty"`
+    Phase string `json:"phase,omitempty"`
+    DurationMs int64 `json:"durationMs,omitempty"`
+    Message string `json:"message"`
+}
+
+func ParseLogLevel(level string) (int, bool) {
+    for i, name := range logLevelNames {
+        if strings.ToLower(level) == name {
+            return i, true;
+        }
+    }
+    return LogLevelInfo, false;
+}
+
+func (log *Logger) IsEnabled(level int) bool {
+    return level <= log.Level;
+}
+
+func (log *Logger) write(level int, module string, phase string, duration time.Duration, args []interface{}) {
+    if !log.IsEnabled(level) {
+        return;
+    }
+    output := log.Output
+    if output == nil {
+        output = os.Stdout
+    }
+    message := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+    if log.Format != "json" {
+        fmt.Fprintln(output, message)
+        return;
+    }
+    event := LogEvent{Time: time.Now().Format(time.RFC3339), Level: logLevelNames[level], Module: module, Phase: phase, DurationMs: int64(duration / time.Millisecond), Message: message}
+    bytes, err := json.Marshal(event)
+    if err != nil {
+        fmt.Fprintln(output, message)
+        return;
+    }
+    fmt.Fprintln(output, string(bytes))
+}
+
+func (log *Logger) Error(args ...interface{}) {
+    log.write(LogLevelError, "", "", 0, args)
+}
+
+func (log *Logger) Warn(args ...interface{}) {
+    log.write(LogLevelWarn, "", "", 0, args)
+}
+
+func (log *Logger) Info(args ...interface{}) {
+    log.write(LogLevelInfo, "", "", 0, args)
+}
+
+func (log *Logger) Debug(args ...interface{}) {
+    log.write(LogLevelDebug, "", "", 0, args)
+}
+
+func (log *Logger) Trace(args ...interface{}) {
+    log.write(LogLevelTrace, "", "", 0, args)
+}
+
+// Start a phase of work on a module. The messages logged through the phase are tagged with the module and phase name.
+func (log *Logger) StartPhase(module string, phase string) *LogPhase {
+    return &LogPhase{Log: log, Module: module, Phase: phase, Start: time.Now()}
+}
+
+type LogPhase struct {
+    Log *Logger
+    Module string
+    Phase string
+    Start time.Time
+}
+
+func (phase *LogPhase) Error(args ...interface{}) {
+    phase.Log.write(LogLevelError, phase.Module, phase.Phase, 0, args)
+}
+
+func (phase *LogPhase) Warn(args ...interface{}) {
+    phase.Log.write(LogLevelWarn, phase.Module, phase.Phase, 0, args)
+}
+
+func (phase *LogPhase) Info(args ...interface{}) {
+    phase.Log.write(LogLevelInfo, phase.Module, phase.Phase, 0, args)
+}
+
+func (phase *LogPhase) Debug(args ...interface{}) {
+    phase.Log.write(LogLevelDebug, phase.Module, phase.Phase, 0, args)
+}
+
+func (phase *LogPhase) Trace(args ...interface{}) {
+    phase.Log.write(LogLevelTrace, phase.Module, phase.Phase, 0, args)
+}
+
+// Log the end of the phase along with the time it took. Finished phases are debug messages in the text format.
+func (phase *LogPhase) Done() {
+    duration := time.Since(phase.Start)
+    level := LogLevelDebug
+    if phase.Log.Format == "json" {
+        level = LogLevelInfo
+    }
+    phase.Log.write(level, phase.Module, phase.Phase, duration, []interface{}{"Finished", phase.Phase, "for", phase.Module, "in", duration.String()})
+}
@@ -0,0 +1,97 @@
+package main;
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "encoding/json"
+)
+
+func TestParseLogLevel(t *testing.T) {
+    tests := []struct {
+        name string
+        expected int
+        ok bool
+    }{
+        {name: "error", expected: LogLevelError, ok: true},
+        {name: "warn", expected: LogLevelWarn, ok: true},
+        {name: "INFO", expected: LogLevelInfo, ok: true},
+        {name: "debug", expected: LogLevelDebug, ok: true},
+        {name: "trace", expected: LogLevelTrace, ok: true},
+        {name: "verbose", expected: LogLevelInfo, ok: false},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            level, ok := ParseLogLevel(test.name)
+            if level != test.expected || ok != test.ok {
+                t.Errorf("Expected %d and %v but got %d and %v", test.expected, test.ok, level, ok)
+            }
+        })
+    }
+}
+
+func TestLoggerLevel(t *testing.T) {
+    tests := []struct {
+        name string
+        level int
+        expected string
+    }{
+        {name: "error", level: LogLevelError, expected: "error\n"},
+        {name: "warn", level: LogLevelWarn, expected: "error\nwarn\n"},
+        {name: "info", level: LogLevelInfo, expected: "error\nwarn\ninfo\n"},
+        {name: "debug", level: LogLevelDebug, expected: "error\nwarn\ninfo\ndebug\n"},
+        {name: "trace", level: LogLevelTrace, expected: "error\nwarn\ninfo\ndebug\ntrace\n"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var out bytes.Buffer
+            log := &Logger{Level: test.level, Format: "text", Output: &out}
+            log.Error("error")
+            log.Warn("warn")
+            log.Info("info")
+            log.Debug("debug")
+            log.Trace("trace")
+            if out.String() != test.expected {
+                t.Errorf("Expected %q but got %q", test.expected, out.String())
+            }
+        })
+    }
+}
+
+func TestLoggerJson(t *testing.T) {
+    var out bytes.Buffer
+    log := &Logger{Level: LogLevelInfo, Format: "json", Output: &out}
+    log.Warn("Warning:", "mortar", 1)
+    log.Debug("not logged")
+    phase := log.StartPhase("mortar", "install")
+    phase.Info("Processing", "mortar")
+    phase.Done()
+    lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+    if len(lines) != 3 {
+        t.Fatalf("Expected 3 lines but got %q", out.String())
+    }
+    expected := []LogEvent{
+        {Level: "warn", Message: "Warning: mortar 1"},
+        {Level: "info", Module: "mortar", Phase: "install", Message: "Processing mortar"},
+        {Level: "info", Module: "mortar", Phase: "install"},
+    }
+    for i, line := range lines {
+        event := LogEvent{}
+        err := json.Unmarshal([]byte(line), &event)
+        if err != nil {
+            t.Fatalf("Expected a json object but got %q. %v", line, err)
+        }
+        if event.Time == "" {
+            t.Errorf("Expected the time in %q", line)
+        }
+        if event.Level != expected[i].Level || event.Module != expected[i].Module || event.Phase != expected[i].Phase {
+            t.Errorf("Expected the level %q, module %q and phase %q in %q", expected[i].Level, expected[i].Module, expected[i].Phase, line)
+        }
+        if expected[i].Message != "" && event.Message != expected[i].Message {
+            t.Errorf("Expected the message %q but got %q", expected[i].Message, event.Message)
+        }
+    }
+    if !strings.HasPrefix(lines[2], "{") || !strings.Contains(lines[2], "Finished install for mortar") {
+        t.Errorf("Expected the finished phase to be logged but got %q", lines[2])
+    }
+}
@@ -4,12 +4,41 @@ import (
     "os"
     "fmt"
     "path"
+    "sort"
+    "strconv"
     "strings"
     "bpmerror"
 )
 
 type LsCommand struct {
     BpmModuleName string
+    Path DependencyPath
+    Cycles []string
+    // Only the subtrees that contain this module are printed
+    Filter string
+    // The number of levels below the direct dependencies that are printed. -1 prints all of them.
+    Depth int
+    // The module each name resolves to, the same as the module cache picks it when installing
+    Resolved ModuleCache
+    // The commits each module is requested at and the modules that request them
+    Requested map[string]map[string][]string
+    // The version of each name@commit
+    Versions map[string]string
+    // The name in the bpm.json of each dependency, which may be different than the dependency name
+    moduleNames map[string]string
+    // The modules that were resolved and printed. The value is true when it was below an exclusion, since then the
+    // module is missing the excluded modules and is resolved and printed again for the other parents.
+    visited map[string]bool
+    printed map[string]bool
+    contains map[string]bool
+}
+
+// A dependency that was found in the bpm cache
+type LsModule struct {
+    Bpm BpmData
+    Path string
+    Local bool
+    Link string
 }
 
 func (cmd *LsCommand) Name() string {
@@ -32,62 +61,250 @@ func (cmd *LsCommand) IndentAndPrint(indentLevel int, mytext string){
     fmt.Println(text + mytext)
 }
 
+func (cmd *LsCommand) key(name string, commit string) string {
+    return name + "@" + commit;
+}
+
+func (cmd *LsCommand) getFilter() string {
+    index := SliceIndex(len(os.Args), func(i int) bool { return os.Args[i] == "ls" });
+    if len(os.Args) > index + 1 && strings.Index(os.Args[index + 1], "--") != 0 {
+        return os.Args[index + 1];
+    }
+    return "";
+}
+
+// Find the module in the bpm cache. A local folder has priority over the commit, the same as when installing.
+func (cmd *LsCommand) loadModule(itemName string, item *BpmDependency) (*LsModule, error) {
+    module := &LsModule{Path: path.Join(Options.WorkingDir, Options.BpmCachePath, itemName, item.Commit)}
+    localPath := path.Join(Options.BpmCachePath, itemName, Options.LocalModuleName)
+    if PathExists(localPath) {
+        module.Path = localPath
+        module.Local = true
+        if IsLink(localPath) {
+            module.Link, _ = os.Readlink(localPath)
+        }
+    } else if !PathExists(module.Path) {
+        return nil, nil;
+    }
+    err := module.Bpm.LoadDependencyFile(path.Join(module.Path, Options.BpmFileName));
+    if err != nil {
+        return module, bpmerror.New(err, "Error: Could not load the bpm.json file for dependency " + itemName)
+    }
+    if strings.TrimSpace(module.Bpm.Name) == "" {
+        return module, bpmerror.New(nil, "Error: There must be a name field in the bpm.json for " + itemName)
+    }
+    if strings.TrimSpace(module.Bpm.Version) == "" {
+        return module, bpmerror.New(nil, "Error: There must be a version field in the bpm for " + itemName)
+    }
+    return module, nil;
+}
+
+// Walk the dependencies in the same order as the install and add every module to the cache the same way, so the
+// resolved commit of each module is the one the install uses
+func (cmd *LsCommand) resolve(bpm BpmData) {
+    for _, itemName := range bpm.GetInstallKeys(cmd.Path.IsRoot() && !Options.Production) {
+        item, override := dependencyOverrides.Apply(itemName, &cmd.Path, bpm.Dependencies[itemName])
+        if itemName == bpm.Name || cmd.Path.FindCycle(itemName, item.Commit) != "" || cmd.Path.ExcludedBy(itemName) != "" {
+            continue
+        }
+        if cmd.Requested[itemName] == nil {
+            cmd.Requested[itemName] = make(map[string][]string)
+        }
+        cmd.Requested[itemName][item.Commit] = append(cmd.Requested[itemName][item.Commit], cmd.Path.Last())
+        key := cmd.key(itemName, item.Commit)
+        if excluding, exists := cmd.visited[key]; exists && !excluding {
+            continue
+        }
+        cmd.visited[key] = cmd.Path.HasExcludes() || len(item.Exclude) > 0 || dependencyOverrides.HasParents()
+        module, err := cmd.loadModule(itemName, item)
+        if module == nil || err != nil || cmd.Path.FindCycle(module.Bpm.Name, item.Commit) != "" {
+            continue
+        }
+        cmd.Versions[key] = module.Bpm.Version
+        cmd.moduleNames[itemName] = module.Bpm.Name
+        cmd.Resolved.AddLatest(&ModuleCacheItem{Name: module.Bpm.Name, Version: module.Bpm.Version, Commit: item.Commit, Path: module.Path, Override: override != ""})
+        cmd.Path.PushExcluding(module.Bpm.Name, item.Commit, item.Exclude)
+        cmd.resolve(module.Bpm)
+        cmd.Path.Pop()
+    }
+}
+
+// Returns true if the filter module is the dependency or one of the modules below it
+func (cmd *LsCommand) containsFilter(itemName string, item *BpmDependency) bool {
+    if itemName == cmd.Filter {
+        return true;
+    }
+    key := cmd.key(itemName, item.Commit)
+    // The result below an exclusion or with override keys that have parents depends on the path, so it is not reused
+    excluding := cmd.Path.HasExcludes() || len(item.Exclude) > 0 || dependencyOverrides.HasParents()
+    if result, exists := cmd.contains[key]; exists && !excluding {
+        return result;
+    }
+    module, err := cmd.loadModule(itemName, item)
+    if module == nil || err != nil || cmd.Path.FindCycle(module.Bpm.Name, item.Commit) != "" {
+        return false;
+    }
+    result := false
+    cmd.Path.PushExcluding(module.Bpm.Name, item.Commit, item.Exclude)
+    for _, name := range module.Bpm.GetInstallKeys(false) {
+        if name == module.Bpm.Name || cmd.Path.ExcludedBy(name) != "" {
+            continue
+        }
+        dependency, _ := dependencyOverrides.Apply(name, &cmd.Path, module.Bpm.Dependencies[name])
+        if cmd.containsFilter(name, dependency) {
+            result = true
+            break
+        }
+    }
+    cmd.Path.Pop()
+    if !excluding {
+        cmd.contains[key] = result
+    }
+    return result;
+}
+
 func (cmd *LsCommand) PrintDependencies(bpm BpmData, indentLevel int) {
     // Sort the dependency keys so the dependencies always print in the same order
-    sortedKeys := bpm.GetSortedKeys();
+    sortedKeys := bpm.GetInstallKeys(cmd.Path.IsRoot() && !Options.Production);
     for _, itemName := range sortedKeys {
-        item := bpm.Dependencies[itemName]
+        requestedItem := bpm.Dependencies[itemName]
+        item, override := dependencyOverrides.Apply(itemName, &cmd.Path, requestedItem)
         if itemName == bpm.Name {
-            fmt.Println("Warning: Ignoring self dependency for", itemName)
+            Log.Warn("Warning: Ignoring self dependency for", itemName)
             continue
         }
         if item.Url == "" {
-            fmt.Println("Error: No url specified for " + itemName)
+            Log.Error("Error: No url specified for " + itemName)
         }
         if item.Commit == "" {
-            fmt.Println("Error: No commit specified for " + itemName)
+            Log.Error("Error: No commit specified for " + itemName)
+        }
+        if cmd.Filter != "" && !cmd.containsFilter(itemName, item) {
+            continue
         }
         cmd.IndentAndPrintTree(indentLevel, "")
-        workingPath,_ := os.Getwd();
-        itemPath := path.Join(Options.BpmCachePath, itemName)
-        itemClonePath := path.Join(workingPath, itemPath, item.Commit)
-        localPath := path.Join(Options.BpmCachePath, itemName, Options.LocalModuleName)
-        if PathExists(localPath) {
-            itemClonePath = localPath;
-            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ [Local]")
-        } else if !PathExists(itemClonePath) {
+        if excludedBy := cmd.Path.ExcludedBy(itemName); excludedBy != "" {
+            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ " + item.Commit + " [Excluded by " + excludedBy + "]")
+            continue
+        }
+        if cycle := cmd.Path.FindCycle(itemName, item.Commit); cycle != "" {
+            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ " + item.Commit + " [CYCLE] " + cycle)
+            cmd.Cycles = append(cmd.Cycles, cycle)
+            continue
+        }
+        module, err := cmd.loadModule(itemName, item)
+        if module == nil && item.IsOptional() {
+            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ " + item.Commit + " [Optional] [MISSING]")
+            continue
+        }
+        if module == nil {
             cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ " + item.Commit + " [MISSING]")
             continue
+        }
+        key := cmd.key(itemName, item.Commit)
+        text := "--" + itemName + " @ " + item.Commit
+        if version, exists := cmd.Versions[key]; exists {
+            text += " (" + version + ")"
+        }
+        if item.IsDev() {
+            text += " [Dev]"
+        } else if item.IsOptional() {
+            text += " [Optional]"
+        }
+        if override != "" && item.Commit != requestedItem.Commit {
+            text += " [Override " + override + " instead of " + requestedItem.Commit + "]"
+        }
+        deduped := false
+        if module.Link != "" {
+            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ [Linked] " + module.Link)
+        } else if module.Local {
+            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ [Local]")
+        } else if resolved, exists := cmd.Resolved.Items[module.Bpm.Name]; exists && resolved.Commit != item.Commit {
+            cmd.IndentAndPrintTree(indentLevel, text + " [Overridden by " + resolved.Commit + " (" + resolved.Version + ")]")
+        } else if excluding, exists := cmd.printed[key]; exists && !excluding {
+            cmd.IndentAndPrintTree(indentLevel, text + " [Deduped]")
+            deduped = true
         } else {
-            cmd.IndentAndPrintTree(indentLevel, "--" + itemName + " @ " + item.Commit + " [Installed]")
+            cmd.IndentAndPrintTree(indentLevel, text + " [Resolved]")
         }
-
-        // Recursively get dependencies in the current dependency
-        moduleBpm := BpmData{};
-        moduleBpmFilePath := path.Join(itemClonePath, Options.BpmFileName)
-        err := moduleBpm.LoadFile(moduleBpmFilePath);
         if err != nil {
-            cmd.IndentAndPrint(indentLevel, "Error: Could not load the bpm.json file for dependency " + itemName)
+            cmd.IndentAndPrint(indentLevel, err.Error())
             continue
         }
-
-        if strings.TrimSpace(moduleBpm.Name) == "" {
-            msg := "Error: There must be a name field in the bpm.json for " + itemName
-            cmd.IndentAndPrint(indentLevel, msg)
+        // The dependencies of a module are only printed the first time the module is printed
+        if deduped || (cmd.Depth >= 0 && indentLevel >= cmd.Depth) {
             continue
         }
+        cmd.printed[key] = cmd.Path.HasExcludes() || len(item.Exclude) > 0 || dependencyOverrides.HasParents()
 
-        if strings.TrimSpace(moduleBpm.Version) == "" {
-            msg := "Error: There must be a version field in the bpm for" + itemName
-            cmd.IndentAndPrint(indentLevel, msg)
+        // The name in the bpm.json may be different than the dependency name
+        if cycle := cmd.Path.FindCycle(module.Bpm.Name, item.Commit); cycle != "" {
+            cmd.IndentAndPrint(indentLevel, "[CYCLE] " + cycle)
+            cmd.Cycles = append(cmd.Cycles, cycle)
             continue
         }
-        cmd.PrintDependencies(moduleBpm, indentLevel + 1)
+        cmd.Path.PushExcluding(module.Bpm.Name, item.Commit, item.Exclude)
+        cmd.PrintDependencies(module.Bpm, indentLevel + 1)
+        cmd.Path.Pop()
     }
     return;
 }
 
+// Highlight the text when the output is a terminal
+func (cmd *LsCommand) highlight(text string) string {
+    if info, err := os.Stdout.Stat(); err != nil || info.Mode() & os.ModeCharDevice == 0 {
+        return text;
+    }
+    return "\033[1;33m" + text + "\033[0m";
+}
+
+// Print the modules that are requested at more than one commit and the commit that is used
+func (cmd *LsCommand) PrintConflicts() {
+    names := []string{}
+    for name, commits := range cmd.Requested {
+        if len(commits) > 1 {
+            names = append(names, name)
+        }
+    }
+    if len(names) == 0 {
+        return;
+    }
+    sort.Strings(names)
+    fmt.Println(cmd.highlight("Conflicts (" + strconv.Itoa(len(names)) + ")"))
+    for _, name := range names {
+        resolved := "unknown"
+        if item, exists := cmd.Resolved.Items[cmd.moduleNames[name]]; exists {
+            resolved = item.Commit + " (" + item.Version + ")"
+            if strings.HasSuffix(item.Path, "/" + Options.LocalModuleName) {
+                resolved = "[Local]"
+            }
+        }
+        fmt.Println(cmd.highlight("   " + name + " resolved to " + resolved))
+        commits := []string{}
+        for commit := range cmd.Requested[name] {
+            commits = append(commits, commit)
+        }
+        sort.Strings(commits)
+        for _, commit := range commits {
+            text := "      " + commit
+            if version, exists := cmd.Versions[cmd.key(name, commit)]; exists {
+                text += " (" + version + ")"
+            }
+            fmt.Println(text + " requested by " + strings.Join(cmd.Requested[name][commit], ", "))
+        }
+    }
+    fmt.Println("")
+}
+
 func (cmd *LsCommand) Execute() (error) {
+    cmd.Depth = -1
+    if Options.LsDepth != "" {
+        depth, err := strconv.Atoi(Options.LsDepth)
+        if err != nil || depth < 0 {
+            return bpmerror.NewKind(bpmerror.Usage, err, "Error: The --depth= option must be a number of 0 or more")
+        }
+        cmd.Depth = depth
+    }
     err := Options.DoesBpmFileExist();
     if err != nil {
         return err;
@@ -96,16 +313,32 @@ func (cmd *LsCommand) Execute() (error) {
     bpm := BpmData{};
     err = bpm.LoadFile(Options.BpmFileName);
     if err != nil {
-        return bpmerror.New(err, "Error: There was a problem loading the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
     }
     if !bpm.HasDependencies() {
-        fmt.Println("There are no dependencies. Done.")
+        Log.Info("There are no dependencies. Done.")
         return nil;
     }
 
+    dependencyOverrides.Load(&bpm)
+    cmd.Filter = cmd.getFilter()
+    cmd.Resolved = ModuleCache{Items: make(map[string]*ModuleCacheItem)}
+    cmd.Requested = make(map[string]map[string][]string)
+    cmd.Versions = make(map[string]string)
+    cmd.moduleNames = make(map[string]string)
+    cmd.visited = make(map[string]bool)
+    cmd.printed = make(map[string]bool)
+    cmd.contains = make(map[string]bool)
+    cmd.Path.Push(bpm.Name, "")
+    cmd.resolve(bpm)
+
     fmt.Println("")
     fmt.Println(bpm.Name)
     cmd.PrintDependencies(bpm, 0)
     fmt.Println("")
+    cmd.PrintConflicts()
+    if len(cmd.Cycles) > 0 && Options.Cycles == "fail" {
+        return bpmerror.NewKind(bpmerror.Conflict, nil, "Error: There are cycles in the dependencies\n" + strings.Join(cmd.Cycles, "\n"))
+    }
     return nil;
 }
@@ -2,11 +2,12 @@ package main;
 
 import (
     "github.com/blang/semver"
-    "fmt"
     "strings"
     "os"
     "path"
+    "path/filepath"
     "io/ioutil"
+    "sort"
     "bpmerror"
 )
 
@@ -18,30 +19,209 @@ func (r *ModuleCache) Delete(item string) {
     delete(r.Items, item)
 }
 
+// Returns a copy of the cache. The items are not changed once they are added, so they are shared.
+func (r *ModuleCache) Clone() ModuleCache {
+    clone := ModuleCache{Items: make(map[string]*ModuleCacheItem)}
+    for name, item := range r.Items {
+        clone.Items[name] = item
+    }
+    return clone;
+}
+
 func (r *ModuleCache) Install() (error) {
-    if Options.PackageManager == "npm" {
-        return r.NpmInstall()
+    var err error;
+    if Options.InstallMode == "package-json" {
+        err = r.PackageJsonInstall()
+    } else if Options.PackageManager == "npm" {
+        err = r.NpmInstall()
     } else if Options.PackageManager == "yarn" {
-        return r.CopyAndYarnInstall("./node_modules");
+        err = r.CopyAndYarnInstall("./node_modules");
     } else {
-        return bpmerror.New(nil, "Error: Unrecognized package manager " + Options.PackageManager)
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: Unrecognized package manager " + Options.PackageManager)
+    }
+    if err == nil && Options.InstallMode != "package-json" {
+        err = r.LinkNodeModules("./node_modules")
+    }
+    return err;
+}
+
+// Run the package manager install for the modules in the cache. The preinstall and postinstall scripts of the modules
+// that are new or have a different commit than the commit that was installed to node_modules run before and after the
+// install, even when the package manager install is skipped.
+func (r *ModuleCache) InstallAndRunScripts() (error) {
+    updatedModules, err := r.UpdatedModules()
+    if err != nil {
+        return err;
+    }
+    err = updatedModules.RunScripts("preinstall")
+    if err != nil {
+        return err;
+    }
+    if !Options.SkipNpmInstall {
+        err = r.Install()
+        if err != nil {
+            return err;
+        }
+    }
+    err = updatedModules.RunScripts("postinstall")
+    if err != nil || Options.SkipNpmInstall {
+        return err;
     }
+    // Remember what bpm installed so the packages can be pruned when they are no longer required. The commits are only
+    // recorded after the postinstall scripts succeeded, so the scripts run again when the install failed.
+    return RecordInstalledPackages(r);
+}
+
+// Run the lifecycle script for every module in the cache. The modules are processed sorted by name so the order is consistent
+func (r *ModuleCache) RunScripts(event string) (error) {
+    names := make([]string, 0, len(r.Items))
+    for depName := range r.Items {
+        names = append(names, depName)
+    }
+    sort.Strings(names)
+    for _, depName := range names {
+        depItem := r.Items[depName];
+        commit := depItem.Commit
+        if commit == "" {
+            commit = Options.LocalModuleName
+        }
+        script := ScriptExec{Path: depItem.Path, Name: depItem.Name, Version: depItem.Version, Commit: commit}
+        err := script.Run(event, depItem.Scripts)
+        if err != nil {
+            return err;
+        }
+    }
+    return nil;
+}
+
+// Returns the modules with a different commit than the commit that was installed to node_modules. The local modules
+// are always updated.
+func (r *ModuleCache) UpdatedModules() (*ModuleCache, error) {
+    installed, err := LoadInstalledPackages()
+    if err != nil {
+        return nil, err;
+    }
+    updated := &ModuleCache{Items: make(map[string]*ModuleCacheItem)}
+    for name, item := range r.Items {
+        if item.Commit == "" || installed.Packages[item.Name] != item.Commit {
+            updated.Items[name] = item
+        }
+    }
+    return updated, nil;
 }
 
 func (r *ModuleCache) NpmInstall() (error){
-    fmt.Println("Npm installing dependencies to node_modules...")
+    Log.Info("Npm installing dependencies to node_modules...")
     workingPath,_ := os.Getwd();
     npm := NpmExec{Path: workingPath}
     // Go through each item in the bpm memory cache. There is suppose to only be one item per dependency
     for depName := range r.Items {
-        fmt.Println("Processing cached dependency", depName)
         depItem := r.Items[depName];
+        if depItem.Linked {
+            continue;
+        }
+        phase := Log.StartPhase(depName, "install")
+        phase.Info("Processing cached dependency", depName)
+        // Replace the link from a previous --link install
+        if IsLink(path.Join(workingPath, "node_modules", depName)) {
+            os.Remove(path.Join(workingPath, "node_modules", depName))
+        }
+        // A remote module in the bpm cache is the whole git clone. The files are synced to the install folder of the
+        // module without the excluded files and the files in the .bpmignore, the same as a local module, and npm
+        // installs that folder. npm links the folder to node_modules, so it is kept in the bpm cache.
+        installPath := depItem.Path
+        if depItem.Commit != "" {
+            installPath = path.Join(Options.BpmCachePath, depItem.Name, Options.InstallModuleName)
+            copyDir := CopyDir{Exclude:Options.ExcludeFileList, IgnoreFile: Options.IgnoreFileName, Sync: true, Checksum: Options.Checksum}
+            err := copyDir.Copy(depItem.Path, installPath);
+            if err != nil {
+                return bpmerror.New(err, "Error: Failed to copy module " + depName + " to " + installPath)
+            }
+            phase.Debug("Synced", installPath + ":", copyDir.Summary())
+        }
         // Perform the npm install and pass the url of the dependency. npm install ./bpm_modules/mydep
-        err := npm.InstallUrl(depItem.Path)
+        err := npm.InstallUrl(installPath)
         if err != nil {
-            return bpmerror.New(err, "Error: Failed to npm install module " + depName)
+            return bpmerror.NewKind(bpmerror.PackageManager, err, "Error: Failed to npm install module " + depName)
+        }
+        phase.Done()
+
+    }
+    return nil;
+}
+
+// Write a file: reference for every module in the cache to the dependencies of the root package.json and then run one
+// package manager install. The lockfile then records the bpm modules as well, so npm ci can reproduce the node_modules.
+func (r *ModuleCache) PackageJsonInstall() (error) {
+    if Options.PackageManager != "npm" && Options.PackageManager != "yarn" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: Unrecognized package manager " + Options.PackageManager)
+    }
+    pkg, err := LoadPackageJson(path.Join(Options.WorkingDir, "package.json"))
+    if err != nil {
+        return err;
+    }
+    dependencies, err := pkg.GetDependencies()
+    if err != nil {
+        return err;
+    }
+    for depName, depItem := range r.Items {
+        reference := PackageJsonReference(depItem.Path)
+        existing, exists := dependencies[depName]
+        if exists && !IsPackageJsonReference(existing) && existing != reference {
+            Log.Warn("Warning: Replacing the dependency", depName, existing, "in package.json with", reference)
         }
+        dependencies[depName] = reference
+    }
+    pkg.SetDependencies(dependencies)
+    Log.Info("Writing the bpm dependencies to", pkg.File)
+    err = pkg.WriteFile()
+    if err != nil {
+        return err;
+    }
+    return RunPackageManagerInstall();
+}
 
+// Run one install of the root package.json with the package manager
+func RunPackageManagerInstall() (error) {
+    phase := Log.StartPhase("", "install")
+    phase.Info("Running", Options.PackageManager, "install in", Options.WorkingDir)
+    var err error;
+    if Options.PackageManager == "yarn" {
+        yarn := YarnExec{Path: Options.WorkingDir}
+        err = yarn.Install()
+    } else {
+        npm := NpmExec{Path: Options.WorkingDir}
+        err = npm.Install()
+    }
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.PackageManager, err, "Error: Failed to " + Options.PackageManager + " install the package.json dependencies")
+    }
+    phase.Done()
+    return nil;
+}
+
+// Link the modules that were linked with the --link option to the node_modules folder. The link points to the link in the
+// bpm cache which points to the local source folder, so the changes in the source folder are used right away.
+func (r *ModuleCache) LinkNodeModules(nodeModulesPath string) (error) {
+    for depName := range r.Items {
+        depItem := r.Items[depName];
+        if !depItem.Linked {
+            continue;
+        }
+        if _, err := os.Stat(nodeModulesPath); os.IsNotExist(err) {
+            os.Mkdir(nodeModulesPath, 0777)
+        }
+        nodeModulesItemPath := path.Join(nodeModulesPath, depName);
+        Log.Info("Linking", nodeModulesItemPath, "to", depItem.Path)
+        os.RemoveAll(nodeModulesItemPath);
+        target, err := filepath.Rel(path.Dir(nodeModulesItemPath), depItem.Path)
+        if err != nil {
+            target = path.Join(Options.WorkingDir, depItem.Path)
+        }
+        err = os.Symlink(target, nodeModulesItemPath)
+        if err != nil {
+            return bpmerror.New(err, "Error: Failed to link module " + depName + " to the node_modules folder")
+        }
     }
     return nil;
 }
@@ -52,8 +232,8 @@ func (r *ModuleCache) Trim() {
         // Delete previous cached items
         entries, _ := ioutil.ReadDir(path.Join(Options.BpmCachePath, depItem.Name))
         for _, entry := range entries {
-            if entry.IsDir() && entry.Name() != Options.LocalModuleName && entry.Name() != depItem.Commit {
-                fmt.Println("Removing previous cache item ...", path.Join(depItem.Name, entry.Name()))
+            if entry.IsDir() && entry.Name() != Options.LocalModuleName && entry.Name() != Options.InstallModuleName && entry.Name() != depItem.Commit {
+                Log.Info("Removing previous cache item ...", path.Join(depItem.Name, entry.Name()))
                 os.RemoveAll(path.Join(Options.BpmCachePath, depItem.Name, entry.Name()))
             }
         }
@@ -61,9 +241,9 @@ func (r *ModuleCache) Trim() {
 }
 
 func (r *ModuleCache) CopyAndYarnInstall(nodeModulesPath string) (error) {
-    fmt.Println("Copying dependencies to the node_modules folder")
+    Log.Info("Copying dependencies to the node_modules folder")
     if _, err := os.Stat(nodeModulesPath); os.IsNotExist(err) {
-        fmt.Println("node_modules folder not found. Creating it...")
+        Log.Debug("node_modules folder not found. Creating it...")
         os.Mkdir(nodeModulesPath, 0777)
     }
 
@@ -71,56 +251,72 @@ func (r *ModuleCache) CopyAndYarnInstall(nodeModulesPath string) (error) {
     yarn.ParseOptions(os.Args);
     // Go through each item in the bpm memory cache. There is suppose to only be one item per dependency
     for depName := range r.Items {
-        fmt.Println("Processing cached dependency", depName)
         depItem := r.Items[depName];
+        if depItem.Linked {
+            continue;
+        }
+        phase := Log.StartPhase(depName, "install")
+        phase.Info("Processing cached dependency", depName)
         nodeModulesItemPath := path.Join(nodeModulesPath, depName);
-        // Delete existing copy in node_modules
-        os.RemoveAll(nodeModulesItemPath);
+        // Replace the link from a previous --link install. A copy is synced so only the changed files are copied.
+        if IsLink(nodeModulesItemPath) {
+            os.Remove(nodeModulesItemPath)
+        }
 
         // Perform the yarn install
         yarn.Path = depItem.Path;
         err := yarn.Install()
         if err != nil {
-            return bpmerror.New(err, "Error: Failed to yarn install module " + depName)
+            return bpmerror.NewKind(bpmerror.PackageManager, err, "Error: Failed to yarn install module " + depName)
         }
 
         // Copy the library to the node_modules folder
-        copyDir := CopyDir{Exclude:Options.ExcludeFileList}
+        copyDir := CopyDir{Exclude:Options.ExcludeFileList, IgnoreFile: Options.IgnoreFileName, Sync: true, Checksum: Options.Checksum}
         err = copyDir.Copy(depItem.Path, nodeModulesItemPath);
         if err != nil {
             return bpmerror.New(err, "Error: Failed to copy module to node_modules folder")
         }
+        phase.Debug("Synced", nodeModulesItemPath + ":", copyDir.Summary())
+        phase.Done()
     }
     return nil;
 }
 
 func (r *ModuleCache) CopyAndNpmInstall(nodeModulesPath string) (error){
-    fmt.Println("Copying dependencies to the node_modules folder")
+    Log.Info("Copying dependencies to the node_modules folder")
     if _, err := os.Stat(nodeModulesPath); os.IsNotExist(err) {
-        fmt.Println("node_modules folder not found. Creating it...")
+        Log.Debug("node_modules folder not found. Creating it...")
         os.Mkdir(nodeModulesPath, 0777)
     }
     npm := NpmExec{Path: path.Join(nodeModulesPath, "..")}
     // Go through each item in the bpm memory cache. There is suppose to only be one item per dependency
     for depName := range r.Items {
-        fmt.Println("Processing cached dependency", depName)
         depItem := r.Items[depName];
+        if depItem.Linked {
+            continue;
+        }
+        phase := Log.StartPhase(depName, "install")
+        phase.Info("Processing cached dependency", depName)
         nodeModulesItemPath := path.Join(nodeModulesPath, depName);
-        // Delete existing copy in node_modules
-        os.RemoveAll(nodeModulesItemPath);
+        // Replace the link from a previous --link install. A copy is synced so only the changed files are copied.
+        if IsLink(nodeModulesItemPath) {
+            os.Remove(nodeModulesItemPath)
+        }
 
         // Copy the library to the node_modules folder
-        copyDir := CopyDir{Exclude:Options.ExcludeFileList}
+        copyDir := CopyDir{Exclude:Options.ExcludeFileList, IgnoreFile: Options.IgnoreFileName, Sync: true, Checksum: Options.Checksum}
         err := copyDir.Copy(depItem.Path, nodeModulesItemPath);
         if err != nil {
             return bpmerror.New(err, "Error: Failed to copy module to node_modules folder")
         }
+        phase.Debug("Synced", nodeModulesItemPath + ":", copyDir.Summary())
 
         // Perform the npm install and pass the url of the dependency. npm install ./node_modules/mydep
         err = npm.InstallUrl(path.Join("./node_modules", depName))
         if err != nil {
-            return bpmerror.New(err, "Error: Failed to npm install module " + depName)
+            return bpmerror.NewKind(bpmerror.PackageManager, err, "Error: Failed to npm install module " + depName)
         }
+        phase.Done()
     }
     return nil;
 }
@@ -141,37 +337,45 @@ func (r *ModuleCache) AddLatest(item *ModuleCacheItem) (bool, error) {
         return r.Add(item), nil
     }
 
+    // A commit that is forced by an override has priority over the other commits
+    if existingItem.Override && !item.Override {
+        return false, nil;
+    }
+    if item.Override && !existingItem.Override {
+        return r.Add(item), nil
+    }
+
     if Options.ConflictResolutionType == "revisionlist" {
-        fmt.Println("Attempting to determine which commit is the ancestor...")
+        Log.Debug("Attempting to determine which commit is the ancestor...")
         // If commitB is printed, then commitA is an ancestor of commit B
         //"git rev-list <commitA> | grep $(git rev-parse <commitB>)"
         git := GitExec{Path: item.Path}
         result := git.DetermineAncestor(item.Commit, existingItem.Commit)
         if result == item.Commit {
-            fmt.Println("The commit " + item.Commit + " is an ancestor of the existing cache item. Replacing existing item with new item.")
+            Log.Info("The commit " + item.Commit + " is an ancestor of the existing cache item. Replacing existing item with new item.")
         } else {
             return false, nil
         }
     } else if Options.ConflictResolutionType == "versioning" {
         v1, err := semver.Make(existingItem.Version)
         if err != nil {
-            fmt.Println("Warning: There was a problem reading the version")
+            Log.Warn("Warning: There was a problem reading the version")
             return false, nil;
         }
         v2, err := semver.Make(item.Version)
         if err != nil {
-            fmt.Println("Warning: There was a problem reading the version")
+            Log.Warn("Warning: There was a problem reading the version")
             return false, nil;
         }
         versionCompareResult := v1.Compare(v2);
         if versionCompareResult == -1 {
-            fmt.Println("Ignoring lower version of ", item.Name);
+            Log.Debug("Ignoring lower version of", item.Name);
             return false, nil;
         } else if versionCompareResult == 0 && strings.Compare(existingItem.Commit, item.Commit) != 0 {
-            fmt.Println("Conflict. The version number is the same, but the commit hash is different. Ignoring")
+            Log.Warn("Conflict. The version number is the same, but the commit hash is different. Ignoring", item.Name, item.Commit)
             return false, nil
         } else if versionCompareResult == 1 {
-            fmt.Println("The version number is greater and this version of the module will be used.")
+            Log.Info("The version number of", item.Name, "is greater and this version of the module will be used.")
         } else {
             // The version number is the same...
             return false, nil;
@@ -5,4 +5,8 @@ type ModuleCacheItem struct {
     Version string
     Commit string
     Path string
+    Scripts map[string]string
+    Linked bool
+    // The commit was forced by an override in the root bpm.json
+    Override bool
 }
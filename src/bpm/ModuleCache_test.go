@@ -0,0 +1,118 @@
+package main;
+
+import (
+    "os"
+    "os/exec"
+    "testing"
+    "io/ioutil"
+    "path/filepath"
+)
+
+// Change to the project folder and restore the working folder and the options after the test
+func useProject(t *testing.T, folder string) func() {
+    workingDir, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    err = os.Chdir(folder)
+    if err != nil {
+        t.Fatal(err)
+    }
+    options := Options
+    Options.WorkingDir = folder
+    return func() {
+        os.Chdir(workingDir)
+        Options = options
+    };
+}
+
+func writeFiles(t *testing.T, folder string, files map[string]string) {
+    for name, content := range files {
+        file := filepath.Join(folder, filepath.FromSlash(name))
+        os.MkdirAll(filepath.Dir(file), 0777)
+        err := ioutil.WriteFile(file, []byte(content), 0666)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+// Runs a real npm install of a remote module in the bpm cache
+func TestNpmInstall(t *testing.T) {
+    if _, err := exec.LookPath("npm"); err != nil {
+        t.Skip("npm is not installed")
+    }
+    for _, name := range []string{"npm_config_audit", "npm_config_fund", "npm_config_update_notifier"} {
+        defer os.Setenv(name, os.Getenv(name))
+        os.Setenv(name, "false")
+    }
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useProject(t, temp)()
+    Options.ExcludeFileList = Options.ExcludeFileList + "|*.log"
+    writeFiles(t, temp, map[string]string{"package.json": `{"name": "app", "version": "1.0.0"}`})
+    writeFiles(t, filepath.Join(temp, "bpm_modules", "mortar", "aaaaaaa"), map[string]string{
+        "package.json": `{"name": "mortar", "version": "1.0.0"}`,
+        "bpm.json": `{"name": "mortar", "version": "1.0.0", "dependencies": {}}`,
+        "index.js": "module.exports = 1;",
+        ".bpmignore": "docs/",
+        "docs/guide.md": "guide",
+        "debug.log": "log",
+        ".git/HEAD": "ref: refs/heads/master",
+    })
+    cache := &ModuleCache{Items: map[string]*ModuleCacheItem{
+        "mortar": {Name: "mortar", Version: "1.0.0", Commit: "aaaaaaa", Path: "bpm_modules/mortar/aaaaaaa"},
+    }}
+    err := cache.NpmInstall()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dat, err := ioutil.ReadFile(filepath.Join(temp, "node_modules", "mortar", "index.js"))
+    if err != nil || string(dat) != "module.exports = 1;" {
+        t.Fatalf("Expected the module to be installed to node_modules but got %q %v", string(dat), err)
+    }
+    for _, name := range []string{"docs", "debug.log", ".git"} {
+        if PathExists(filepath.Join(temp, "node_modules", "mortar", name)) {
+            t.Errorf("Expected %s to be excluded from node_modules", name)
+        }
+    }
+    if !PathExists(filepath.Join(temp, "bpm_modules", "mortar", "aaaaaaa", "docs")) {
+        t.Errorf("Expected the module in the bpm cache to be unchanged")
+    }
+}
+
+func TestInstallAndRunScripts(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useProject(t, temp)()
+    Options.SkipNpmInstall = true
+    log := filepath.Join(temp, "scripts.log")
+    scripts := map[string]string{"preinstall": "echo pre $BPM_MODULE_NAME >> " + log, "postinstall": "echo post $BPM_MODULE_NAME >> " + log}
+    cache := &ModuleCache{Items: make(map[string]*ModuleCacheItem)}
+    for _, item := range []*ModuleCacheItem{
+        {Name: "same", Version: "1.0.0", Commit: "aaaaaaa"},
+        {Name: "changed", Version: "1.0.0", Commit: "bbbbbbb"},
+        {Name: "new", Version: "1.0.0", Commit: "ccccccc"},
+        {Name: "local", Version: "1.0.0"},
+    } {
+        item.Path = filepath.Join(temp, "bpm_modules", item.Name)
+        item.Scripts = scripts
+        os.MkdirAll(item.Path, 0777)
+        cache.Items[item.Name] = item
+    }
+    writeFiles(t, temp, map[string]string{
+        "node_modules/" + Options.InstalledFileName: `{"packages": {"same": "aaaaaaa", "changed": "9999999", "local": "local"}}`,
+    })
+    err := cache.InstallAndRunScripts()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dat, err := ioutil.ReadFile(log)
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := "pre changed\npre local\npre new\npost changed\npost local\npost new\n"
+    if string(dat) != expected {
+        t.Errorf("Expected the scripts to only run for the new and changed modules\n%s\nbut got\n%s", expected, string(dat))
+    }
+}
@@ -1,15 +1,11 @@
 package main;
 
-import (
-    "fmt"
-)
-
 type NpmExec struct {
     Path string
 }
 
 func (npm *NpmExec) Uninstall(item string) error {
-    fmt.Println("Running npm uninstall on", item);
+    Log.Info("Running npm uninstall on", item);
     npmCommand := "npm uninstall " + item
     rc := OsExec{Dir: npm.Path, LogOutput: true}
     _, err := rc.Run(npmCommand)
@@ -20,7 +16,7 @@ func (npm *NpmExec) Uninstall(item string) error {
 }
 
 func (npm *NpmExec) InstallUrl(url string) error {
-    fmt.Println("Running npm install in", npm.Path, "on", url)
+    Log.Debug("Running npm install in", npm.Path, "on", url)
     // git clone <repo url> <destination directory>
     // npm cache clean
     npmCommand := "npm install " + url
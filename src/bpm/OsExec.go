@@ -5,7 +5,6 @@ import  (
     "strings"
     "errors"
     "os/exec"
-    "fmt"
     "bytes"
 )
 
@@ -19,14 +18,18 @@ func (rc *OsExec) Run(command string) (string, error) {
     if command == "" {
         return "", errors.New("The command cannot be empty")
     }
-    splitCmd := strings.Split(command, " ")
+    return rc.RunArgs(strings.Split(command, " "))
+}
+
+// Run the command with the arguments as they are. Use this when an argument can contain spaces.
+func (rc *OsExec) RunArgs(splitCmd []string) (string, error) {
     cmd := exec.Command(splitCmd[0])
     if rc.Dir != "" {
         cmd.Dir = rc.Dir;
     }
     cmd.Args = splitCmd;
     if rc.LogOutput {
-        fmt.Println(cmd.Args)
+        Log.Debug(cmd.Args)
     }
 	var out bytes.Buffer
     var errOut bytes.Buffer
@@ -34,10 +37,13 @@ func (rc *OsExec) Run(command string) (string, error) {
     cmd.Stderr = &errOut
 	err := cmd.Run()
     if rc.LogOutput {
-        fmt.Println(out.String())
-        fmt.Println(errOut.String())
+        Log.Trace(out.String())
+        Log.Trace(errOut.String())
     }
 	if err != nil {
+        if rc.LogOutput && !Log.IsEnabled(LogLevelTrace) {
+            Log.Debug(out.String() + errOut.String())
+        }
         return out.String() + errOut.String(), err;
 	}
     return out.String(), nil
@@ -0,0 +1,141 @@
+package main;
+
+import (
+    "os"
+    "sort"
+    "bytes"
+    "strings"
+    "io/ioutil"
+    "encoding/json"
+    "path/filepath"
+    "bpmerror"
+)
+
+// The root package.json. The fields are written back in the original order.
+type PackageJson struct {
+    File string
+    keys []string
+    values map[string]json.RawMessage
+}
+
+func LoadPackageJson(file string) (*PackageJson, error) {
+    pkg := &PackageJson{File: file, keys: []string{}, values: make(map[string]json.RawMessage)}
+    dat, err := ioutil.ReadFile(file)
+    if os.IsNotExist(err) {
+        return pkg, nil;
+    }
+    if err != nil {
+        return nil, bpmerror.New(err, "Error: There was a problem reading the file " + file)
+    }
+    decoder := json.NewDecoder(bytes.NewReader(dat))
+    token, err := decoder.Token()
+    if err != nil || token != json.Delim('{') {
+        return nil, bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: The file " + file + " is not a json object")
+    }
+    for decoder.More() {
+        token, err = decoder.Token()
+        if err != nil {
+            return nil, bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem reading the file " + file)
+        }
+        key := token.(string)
+        var value json.RawMessage
+        err = decoder.Decode(&value)
+        if err != nil {
+            return nil, bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem reading the file " + file)
+        }
+        if _, exists := pkg.values[key]; !exists {
+            pkg.keys = append(pkg.keys, key)
+        }
+        pkg.values[key] = value
+    }
+    return pkg, nil;
+}
+
+// Set a field to a string value. A new field is added at the end.
+func (pkg *PackageJson) SetString(key string, value string) {
+    dat, _ := json.Marshal(value)
+    if _, exists := pkg.values[key]; !exists {
+        pkg.keys = append(pkg.keys, key)
+    }
+    pkg.values[key] = json.RawMessage(dat)
+}
+
+func (pkg *PackageJson) GetDependencies() (map[string]string, error) {
+    dependencies := make(map[string]string)
+    value, exists := pkg.values["dependencies"]
+    if !exists {
+        return dependencies, nil;
+    }
+    err := json.Unmarshal(value, &dependencies)
+    if err != nil {
+        return nil, bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: The dependencies in the file " + pkg.File + " are not valid")
+    }
+    return dependencies, nil;
+}
+
+// Set the dependencies. The dependencies are sorted by name, the same as npm writes them.
+func (pkg *PackageJson) SetDependencies(dependencies map[string]string) {
+    names := make([]string, 0, len(dependencies))
+    for name := range dependencies {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var buffer bytes.Buffer
+    buffer.WriteString("{")
+    for i, name := range names {
+        if i > 0 {
+            buffer.WriteString(",")
+        }
+        key, _ := json.Marshal(name)
+        value, _ := json.Marshal(dependencies[name])
+        buffer.Write(key)
+        buffer.WriteString(":")
+        buffer.Write(value)
+    }
+    buffer.WriteString("}")
+    if _, exists := pkg.values["dependencies"]; !exists {
+        pkg.keys = append(pkg.keys, "dependencies")
+    }
+    pkg.values["dependencies"] = json.RawMessage(buffer.Bytes())
+}
+
+func (pkg *PackageJson) WriteFile() error {
+    var buffer bytes.Buffer
+    buffer.WriteString("{")
+    for i, key := range pkg.keys {
+        if i > 0 {
+            buffer.WriteString(",")
+        }
+        name, _ := json.Marshal(key)
+        buffer.Write(name)
+        buffer.WriteString(":")
+        buffer.Write(pkg.values[key])
+    }
+    buffer.WriteString("}")
+    var out bytes.Buffer
+    err := json.Indent(&out, buffer.Bytes(), "", "  ")
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem writing the file " + pkg.File)
+    }
+    out.WriteString("\n")
+    err = ioutil.WriteFile(pkg.File, out.Bytes(), 0666)
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem writing the file " + pkg.File)
+    }
+    return nil;
+}
+
+// The file: reference for a module in the bpm cache, relative to the working folder. For example file:bpm_modules/mydep/<commit>
+func PackageJsonReference(modulePath string) string {
+    if filepath.IsAbs(modulePath) {
+        if relativePath, err := filepath.Rel(Options.WorkingDir, modulePath); err == nil {
+            modulePath = relativePath
+        }
+    }
+    return "file:" + filepath.ToSlash(filepath.Clean(modulePath));
+}
+
+// Returns true when the dependency value is a reference to the bpm cache that is managed by bpm
+func IsPackageJsonReference(value string) bool {
+    return strings.HasPrefix(value, "file:" + Options.BpmCachePath + "/");
+}
@@ -0,0 +1,122 @@
+package main;
+
+import (
+    "os"
+    "os/exec"
+    "testing"
+    "io/ioutil"
+    "path/filepath"
+)
+
+func TestPackageJsonReference(t *testing.T) {
+    options := Options
+    defer func() { Options = options }()
+    Options.WorkingDir = "/work/app"
+    Options.BpmCachePath = "bpm_modules"
+    tests := []struct {
+        name string
+        modulePath string
+        expected string
+    }{
+        {name: "relative", modulePath: "bpm_modules/mortar/1111111", expected: "file:bpm_modules/mortar/1111111"},
+        {name: "not clean", modulePath: "./bpm_modules/mortar/../mortar/1111111/", expected: "file:bpm_modules/mortar/1111111"},
+        {name: "absolute", modulePath: "/work/app/bpm_modules/mortar/local", expected: "file:bpm_modules/mortar/local"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            reference := PackageJsonReference(test.modulePath)
+            if reference != test.expected {
+                t.Errorf("Expected %q but got %q", test.expected, reference)
+            }
+        })
+    }
+    for value, expected := range map[string]bool{
+        "file:bpm_modules/mortar/1111111": true,
+        "file:vendor/mortar": false,
+        "file:bpm_modules": false,
+        "^1.0.0": false,
+    } {
+        if IsPackageJsonReference(value) != expected {
+            t.Errorf("Expected IsPackageJsonReference(%q) to be %v", value, expected)
+        }
+    }
+}
+
+func TestPackageJsonWriteFile(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    file := filepath.Join(temp, "package.json")
+    writeFiles(t, temp, map[string]string{"package.json": `{"name": "app", "dependencies": {"react": "^16.0.0"}, "scripts": {"test": "jest"}}`})
+    pkg, err := LoadPackageJson(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    dependencies, err := pkg.GetDependencies()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dependencies["mortar"] = "file:bpm_modules/mortar/1111111"
+    pkg.SetDependencies(dependencies)
+    pkg.SetString("version", "1.0.0")
+    err = pkg.WriteFile()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dat, err := ioutil.ReadFile(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The fields keep their order, the dependencies are sorted and a new field is added at the end
+    expected := "{\n  \"name\": \"app\",\n  \"dependencies\": {\n    \"mortar\": \"file:bpm_modules/mortar/1111111\",\n    \"react\": \"^16.0.0\"\n  },\n  \"scripts\": {\n    \"test\": \"jest\"\n  },\n  \"version\": \"1.0.0\"\n}\n"
+    if string(dat) != expected {
+        t.Errorf("Expected\n%s\nbut got\n%s", expected, string(dat))
+    }
+}
+
+// Runs a real npm install of the file: references in the package.json
+func TestPackageJsonInstall(t *testing.T) {
+    if _, err := exec.LookPath("npm"); err != nil {
+        t.Skip("npm is not installed")
+    }
+    for _, name := range []string{"npm_config_audit", "npm_config_fund", "npm_config_update_notifier"} {
+        defer os.Setenv(name, os.Getenv(name))
+        os.Setenv(name, "false")
+    }
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useProject(t, temp)()
+    Options.InstallMode = "package-json"
+    Options.PackageManager = "npm"
+    writeFiles(t, temp, map[string]string{
+        "package.json": `{"name": "app", "version": "1.0.0", "dependencies": {"old": "file:bpm_modules/old/2222222", "mortar": "file:vendor/mortar"}}`,
+        "bpm_modules/mortar/1111111/package.json": `{"name": "mortar", "version": "1.0.0"}`,
+        "bpm_modules/mortar/1111111/index.js": "module.exports = 1;",
+        "bpm_modules/old/2222222/package.json": `{"name": "old", "version": "1.0.0"}`,
+    })
+    cache := &ModuleCache{Items: map[string]*ModuleCacheItem{
+        "mortar": {Name: "mortar", Version: "1.0.0", Commit: "1111111", Path: filepath.Join(temp, "bpm_modules", "mortar", "1111111")},
+    }}
+    err := cache.Install()
+    if err != nil {
+        t.Fatal(err)
+    }
+    pkg, err := LoadPackageJson(filepath.Join(temp, "package.json"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    dependencies, err := pkg.GetDependencies()
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The dependency that is not a bpm reference is replaced with a warning, the old bpm reference is left for the prune
+    if dependencies["mortar"] != "file:bpm_modules/mortar/1111111" || dependencies["old"] != "file:bpm_modules/old/2222222" {
+        t.Errorf("Expected the file: references in the package.json but got %v", dependencies)
+    }
+    dat, err := ioutil.ReadFile(filepath.Join(temp, "node_modules", "mortar", "index.js"))
+    if err != nil || string(dat) != "module.exports = 1;" {
+        t.Errorf("Expected the module to be installed to node_modules but got %q %v", string(dat), err)
+    }
+    if !PathExists(filepath.Join(temp, "package-lock.json")) {
+        t.Errorf("Expected the package-lock.json to record the modules")
+    }
+}
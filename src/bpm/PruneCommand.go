@@ -0,0 +1,256 @@
+package main;
+
+import (
+    "os"
+    "path"
+    "sort"
+    "strings"
+    "io/ioutil"
+    "encoding/json"
+    "bpmerror"
+)
+
+// The packages bpm installed to the node_modules folder. The record is kept in the node_modules folder so it is removed
+// along with the packages when the node_modules folder is deleted.
+type InstalledPackages struct {
+    Packages map[string]string `json:"packages"`
+}
+
+func InstalledPackagesFile() string {
+    return path.Join(Options.WorkingDir, "node_modules", Options.InstalledFileName);
+}
+
+func LoadInstalledPackages() (*InstalledPackages, error) {
+    installed := &InstalledPackages{Packages: make(map[string]string)}
+    dat, err := ioutil.ReadFile(InstalledPackagesFile())
+    if os.IsNotExist(err) {
+        return installed, nil;
+    }
+    if err != nil {
+        return nil, bpmerror.New(err, "Error: There was a problem reading the file " + InstalledPackagesFile())
+    }
+    err = json.Unmarshal(dat, installed)
+    if err != nil {
+        return nil, bpmerror.New(err, "Error: There was a problem reading the file " + InstalledPackagesFile())
+    }
+    if installed.Packages == nil {
+        installed.Packages = make(map[string]string)
+    }
+    return installed, nil;
+}
+
+func (installed *InstalledPackages) Save() error {
+    nodeModulesPath := path.Dir(InstalledPackagesFile())
+    if !PathExists(nodeModulesPath) {
+        return nil;
+    }
+    dat, err := json.MarshalIndent(installed, "", "    ")
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem saving the file " + InstalledPackagesFile())
+    }
+    err = ioutil.WriteFile(InstalledPackagesFile(), dat, 0666)
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem saving the file " + InstalledPackagesFile())
+    }
+    return nil;
+}
+
+func (installed *InstalledPackages) SortedNames() []string {
+    names := make([]string, 0, len(installed.Packages))
+    for name := range installed.Packages {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names;
+}
+
+// Record the modules in the cache as installed
+func RecordInstalledPackages(cache *ModuleCache) error {
+    installed, err := LoadInstalledPackages()
+    if err != nil {
+        return err;
+    }
+    for _, item := range cache.Items {
+        commit := item.Commit
+        if commit == "" {
+            commit = Options.LocalModuleName
+        }
+        installed.Packages[item.Name] = commit
+    }
+    return installed.Save();
+}
+
+// Find the names of all the modules required by the bpm.json using the modules in the bpm cache. A local folder has
+// priority over the commit, the same as when the dependencies are processed. The modules that are not in the bpm cache
+// are added to missing with their commit, since the modules they require are not known. A module below an exclusion is
+// marked in visited with true and is resolved again for the other parents.
+func ResolvedModuleNames(bpm *BpmData, dp *DependencyPath, names map[string]bool, visited map[string]bool, missing map[string]string) error {
+    for _, depName := range bpm.GetInstallKeys(dp.IsRoot() && !Options.Production) {
+        if dp.ExcludedBy(depName) != "" {
+            continue;
+        }
+        dep, _ := dependencyOverrides.Apply(depName, dp, bpm.Dependencies[depName])
+        modulePath := path.Join(Options.BpmCachePath, depName, Options.LocalModuleName)
+        if !PathExists(modulePath) {
+            modulePath = path.Join(Options.BpmCachePath, depName, dep.Commit)
+        }
+        if excluding, exists := visited[modulePath]; exists && !excluding {
+            continue;
+        }
+        visited[modulePath] = dp.HasExcludes() || len(dep.Exclude) > 0 || dependencyOverrides.HasParents()
+        // An optional dependency that could not be fetched is not installed
+        if !PathExists(modulePath) && dep.IsOptional() {
+            continue;
+        }
+        if !PathExists(modulePath) {
+            names[depName] = true
+            missing[depName] = dep.Commit
+            continue;
+        }
+        moduleBpm, err := LoadBpmData(modulePath)
+        if err != nil {
+            return err;
+        }
+        names[moduleBpm.Name] = true
+        if dp.FindCycle(moduleBpm.Name, dep.Commit) != "" {
+            continue;
+        }
+        dp.PushExcluding(moduleBpm.Name, dep.Commit, dep.Exclude)
+        err = ResolvedModuleNames(moduleBpm, dp, names, visited, missing)
+        dp.Pop()
+        if err != nil {
+            return err;
+        }
+    }
+    return nil;
+}
+
+// Find the names of the modules required by the bpm.json and the modules that are missing from the bpm cache
+func ResolveRequiredModules(bpm *BpmData) (map[string]bool, []string, error) {
+    names := make(map[string]bool)
+    missing := make(map[string]string)
+    dependencyOverrides.Load(bpm)
+    dp := &DependencyPath{}
+    dp.Push(bpm.Name, "")
+    err := ResolvedModuleNames(bpm, dp, names, make(map[string]bool), missing)
+    if err != nil {
+        return nil, nil, err;
+    }
+    missingNames := []string{}
+    for name, commit := range missing {
+        missingNames = append(missingNames, name + " @ " + commit)
+    }
+    sort.Strings(missingNames)
+    return names, missingNames, nil;
+}
+
+// Remove the packages bpm installed to node_modules that are no longer in the resolved dependency graph
+func PruneNodeModules(bpm *BpmData) error {
+    installed, err := LoadInstalledPackages()
+    if err != nil {
+        return err;
+    }
+    if len(installed.Packages) == 0 {
+        return nil;
+    }
+    names, missing, err := ResolveRequiredModules(bpm)
+    if err != nil {
+        return err;
+    }
+    // The packages required by a missing module are not known, so nothing is pruned until it is installed
+    if len(missing) > 0 {
+        Log.Warn("Warning: node_modules is not pruned since", strings.Join(missing, ", "), "is missing from", Options.BpmCachePath + ". Run bpm install to prune it.")
+        return nil;
+    }
+    if Options.InstallMode == "package-json" {
+        return prunePackageJson(installed, names);
+    }
+    npm := NpmExec{Path: Options.WorkingDir}
+    pruned := 0
+    for _, name := range installed.SortedNames() {
+        if names[name] {
+            continue;
+        }
+        pruned++
+        if Options.DryRun {
+            Log.Info("Would prune", name, "from node_modules")
+            continue;
+        }
+        Log.Info("Pruning", name, "from node_modules")
+        if Options.PackageManager == "npm" {
+            err = npm.Uninstall(name)
+            if err != nil {
+                return bpmerror.NewKind(bpmerror.PackageManager, err, "Error: Failed to npm uninstall module " + name)
+            }
+        } else {
+            os.RemoveAll(path.Join(Options.WorkingDir, "node_modules", name))
+        }
+        delete(installed.Packages, name)
+    }
+    if pruned == 0 {
+        Log.Debug("There are no packages to prune from node_modules")
+        return nil;
+    }
+    return installed.Save();
+}
+
+// Remove the stale file: references from the package.json and let one package manager install remove the packages
+func prunePackageJson(installed *InstalledPackages, names map[string]bool) error {
+    pkg, err := LoadPackageJson(path.Join(Options.WorkingDir, "package.json"))
+    if err != nil {
+        return err;
+    }
+    dependencies, err := pkg.GetDependencies()
+    if err != nil {
+        return err;
+    }
+    pruned := 0
+    for _, name := range installed.SortedNames() {
+        if names[name] {
+            continue;
+        }
+        pruned++
+        if Options.DryRun {
+            Log.Info("Would prune", name, "from package.json and node_modules")
+            continue;
+        }
+        Log.Info("Pruning", name, "from package.json and node_modules")
+        if IsPackageJsonReference(dependencies[name]) {
+            delete(dependencies, name)
+        }
+        delete(installed.Packages, name)
+    }
+    if pruned == 0 || Options.DryRun {
+        return nil;
+    }
+    pkg.SetDependencies(dependencies)
+    err = pkg.WriteFile()
+    if err != nil {
+        return err;
+    }
+    err = RunPackageManagerInstall()
+    if err != nil {
+        return err;
+    }
+    return installed.Save();
+}
+
+type PruneCommand struct {
+}
+
+func (cmd *PruneCommand) Name() string {
+    return "prune"
+}
+
+func (cmd *PruneCommand) Execute() (error) {
+    err := Options.DoesBpmFileExist();
+    if err != nil {
+        return err;
+    }
+    bpm := &BpmData{};
+    err = bpm.LoadFile(Options.BpmFileName);
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
+    }
+    return PruneNodeModules(bpm);
+}
@@ -0,0 +1,130 @@
+package main;
+
+import (
+    "os"
+    "sort"
+    "strings"
+    "testing"
+    "path/filepath"
+)
+
+func TestRecordInstalledPackages(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useProject(t, temp)()
+    cache := &ModuleCache{Items: map[string]*ModuleCacheItem{
+        "mortar": {Name: "mortar", Commit: "1111111"},
+        "login-client": {Name: "login-client"},
+    }}
+    // Without a node_modules folder there is nothing to record
+    err := RecordInstalledPackages(cache)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if PathExists(InstalledPackagesFile()) {
+        t.Fatalf("Expected no record without a node_modules folder")
+    }
+    writeFiles(t, temp, map[string]string{"node_modules/" + Options.InstalledFileName: `{"packages": {"old": "2222222", "mortar": "0000000"}}`})
+    err = RecordInstalledPackages(cache)
+    if err != nil {
+        t.Fatal(err)
+    }
+    installed, err := LoadInstalledPackages()
+    if err != nil {
+        t.Fatal(err)
+    }
+    expected := map[string]string{"old": "2222222", "mortar": "1111111", "login-client": Options.LocalModuleName}
+    if len(installed.Packages) != len(expected) {
+        t.Errorf("Expected %v but got %v", expected, installed.Packages)
+    }
+    for name, commit := range expected {
+        if installed.Packages[name] != commit {
+            t.Errorf("Expected %s to be recorded at %s but got %s", name, commit, installed.Packages[name])
+        }
+    }
+    writeFiles(t, temp, map[string]string{"node_modules/" + Options.InstalledFileName: `{"packages": `})
+    _, err = LoadInstalledPackages()
+    if err == nil {
+        t.Errorf("Expected an error for an invalid record")
+    }
+}
+
+func TestPruneNodeModules(t *testing.T) {
+    tests := []struct {
+        name string
+        root string
+        dryRun bool
+        expected []string
+    }{
+        {
+            name: "not required",
+            root: `"a": {"url": "../a", "commit": "1111111"}`,
+            expected: []string{"a", "other", "s"},
+        },
+        {
+            name: "excluded",
+            root: `"a": {"url": "../a", "commit": "1111111", "exclude": ["s"]}`,
+            expected: []string{"a", "other"},
+        },
+        {
+            name: "dry run",
+            root: `"a": {"url": "../a", "commit": "1111111"}`,
+            dryRun: true,
+            expected: []string{"a", "old", "other", "s"},
+        },
+        {
+            name: "missing module",
+            root: `"a": {"url": "../a", "commit": "1111111"}, "b": {"url": "../b", "commit": "3333333"}`,
+            expected: []string{"a", "old", "other", "s"},
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            temp := makeTempDir(t)
+            defer os.RemoveAll(temp)
+            defer useProject(t, temp)()
+            defer useBpmCache(t, filepath.Join(temp, "bpm_modules"))()
+            Options.PackageManager = "yarn"
+            Options.DryRun = test.dryRun
+            writeManifest(t, temp, "app", test.root)
+            writeManifest(t, filepath.Join(temp, "bpm_modules", "a", "1111111"), "a", `"s": {"url": "../s", "commit": "2222222"}`)
+            writeManifest(t, filepath.Join(temp, "bpm_modules", "s", "2222222"), "s", ``)
+            // The other package was not installed by bpm, so it is never pruned
+            writeFiles(t, temp, map[string]string{
+                "node_modules/" + Options.InstalledFileName: `{"packages": {"a": "1111111", "s": "2222222", "old": "4444444"}}`,
+                "node_modules/a/package.json": `{}`,
+                "node_modules/s/package.json": `{}`,
+                "node_modules/old/package.json": `{}`,
+                "node_modules/other/package.json": `{}`,
+            })
+            err := PruneNodeModules(loadManifest(t, temp))
+            if err != nil {
+                t.Fatal(err)
+            }
+            packages := []string{}
+            for _, name := range []string{"a", "old", "other", "s"} {
+                if PathExists(filepath.Join(temp, "node_modules", name)) {
+                    packages = append(packages, name)
+                }
+            }
+            if strings.Join(packages, " ") != strings.Join(test.expected, " ") {
+                t.Errorf("Expected the packages %v in node_modules but got %v", test.expected, packages)
+            }
+            installed, err := LoadInstalledPackages()
+            if err != nil {
+                t.Fatal(err)
+            }
+            recorded := installed.SortedNames()
+            expected := []string{}
+            for _, name := range test.expected {
+                if name != "other" {
+                    expected = append(expected, name)
+                }
+            }
+            sort.Strings(expected)
+            if strings.Join(recorded, " ") != strings.Join(expected, " ") {
+                t.Errorf("Expected the packages %v to be recorded but got %v", expected, recorded)
+            }
+        })
+    }
+}
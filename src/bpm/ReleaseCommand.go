@@ -0,0 +1,106 @@
+package main;
+
+import (
+    "os"
+    "path"
+    "strings"
+    "bpmerror"
+)
+
+// Increments the version in the bpm.json, and in the package.json if there is one, then commits the change and tags
+// the commit with v<version>
+type ReleaseCommand struct {
+}
+
+func (cmd *ReleaseCommand) Name() string {
+    return "release"
+}
+
+func (cmd *ReleaseCommand) getReleaseLevel() string {
+    index := SliceIndex(len(os.Args), func(i int) bool { return os.Args[i] == "release" });
+    if len(os.Args) > index + 1 && strings.Index(os.Args[index + 1], "--") != 0 {
+        return os.Args[index + 1];
+    }
+    return "patch";
+}
+
+func (cmd *ReleaseCommand) Execute() (error) {
+    level := cmd.getReleaseLevel()
+    if level != "patch" && level != "minor" && level != "major" {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: The release level must be one of patch, minor or major")
+    }
+    err := Options.DoesBpmFileExist();
+    if err != nil {
+        return err;
+    }
+    bpm := &BpmData{}
+    err = bpm.LoadFile(Options.BpmFileName);
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
+    }
+    err = bpm.Validate();
+    if err != nil {
+        return err;
+    }
+    for _, name := range bpm.GetSortedKeys() {
+        if bpm.Dependencies[name].Commit == Options.LocalModuleName {
+            return bpmerror.NewKind(bpmerror.LocalCommit, nil, "Error: The dependency " + name + " is pinned to '" + Options.LocalModuleName + "'. Commit the changes in " + name + " and run bpm update " + name + " before the release.")
+        }
+    }
+
+    git := GitExec{Path: Options.WorkingDir}
+    if !git.IsGitRepo() {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: " + Options.WorkingDir + " is not a git repository")
+    }
+    uncommitted, err := git.HasUncommittedFiles()
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not get the status of the git repository")
+    }
+    if uncommitted {
+        return bpmerror.NewKind(bpmerror.Precondition, nil, "Error: There are uncommitted changes. Commit or stash the changes before the release.")
+    }
+
+    previousVersion := bpm.Version
+    err = bpm.IncrementVersion(level)
+    if err != nil {
+        return err;
+    }
+    tag := "v" + bpm.Version
+    if git.TagExists(tag) {
+        return bpmerror.NewKind(bpmerror.Precondition, nil, "Error: The tag " + tag + " already exists")
+    }
+    if Options.DryRun {
+        Log.Info("Would release", bpm.Name, "version", previousVersion, "as", bpm.Version, "and tag it", tag)
+        return nil;
+    }
+
+    Log.Info("Releasing", bpm.Name, "version", previousVersion, "as", bpm.Version)
+    files := []string{Options.BpmFileName}
+    err = bpm.WriteFile(path.Join(Options.WorkingDir, Options.BpmFileName))
+    if err != nil {
+        return err;
+    }
+    packageJsonFile := path.Join(Options.WorkingDir, "package.json")
+    if PathExists(packageJsonFile) {
+        pkg, err := LoadPackageJson(packageJsonFile)
+        if err != nil {
+            return err;
+        }
+        pkg.SetString("version", bpm.Version)
+        err = pkg.WriteFile()
+        if err != nil {
+            return err;
+        }
+        files = append(files, "package.json")
+    }
+    err = git.Commit("Release " + tag, files)
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not commit the release")
+    }
+    err = git.Tag(tag, "Release " + tag)
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not create the tag " + tag)
+    }
+    Log.Info("Tagged", tag + ". Push the release with git push --follow-tags")
+    return nil;
+}
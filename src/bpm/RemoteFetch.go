@@ -0,0 +1,91 @@
+package main;
+
+import (
+    "os"
+    "path"
+    "time"
+    "errors"
+    "net/url"
+    "strconv"
+    "strings"
+    "bpmerror"
+)
+
+// The delay before the first retry of a failed fetch. The delay doubles with every retry.
+var fetchRetryDelay = time.Second
+
+// Rewrite the url with the rewrite rules in the config file. The rule with the longest matching prefix is used, the
+// same as the insteadOf setting of git.
+func RewriteUrl(itemUrl string) string {
+    rewrittenUrl := ApplyUrlRules(itemUrl, Options.Config.Rewrite)
+    if rewrittenUrl != itemUrl {
+        Log.Debug("Rewriting", itemUrl, "to", rewrittenUrl)
+    }
+    return rewrittenUrl;
+}
+
+// Replace the longest prefix of the url that matches one of the rules. The url is returned as is when no rule matches.
+func ApplyUrlRules(itemUrl string, rules map[string]string) string {
+    match := ""
+    for prefix := range rules {
+        if strings.HasPrefix(itemUrl, prefix) && len(prefix) > len(match) {
+            match = prefix
+        }
+    }
+    if match == "" {
+        return itemUrl;
+    }
+    return rules[match] + strings.TrimPrefix(itemUrl, match);
+}
+
+// Join a relative url to the path of the parent url
+func JoinRemoteUrl(parentUrl *url.URL, relativeUrl string) string {
+    return parentUrl.Scheme + "://" + path.Join(parentUrl.Host, parentUrl.Path, relativeUrl);
+}
+
+// Make the full urls of the mirrors of a dependency of the root project
+func MakeMirrorUrls(mirrors []string) ([]string, error) {
+    urls := []string{}
+    for _, mirror := range mirrors {
+        mirrorUrl, err := MakeRemoteUrl(mirror)
+        if err != nil {
+            return nil, err;
+        }
+        urls = append(urls, mirrorUrl)
+    }
+    return urls, nil;
+}
+
+// Fetch the repository from the first url that works and checkout the commit. The urls are tried in order. A url that
+// fails with a network error is tried again with the --retries= option and the delay doubles after each attempt.
+// The rewrite rules are applied here, once, to the full urls. Returns the url that was used, before it was rewritten,
+// so the relative urls of its dependencies are joined to it the same way.
+func FetchWithFallback(git GitExec, urls []string, commit string) (string, error) {
+    retries, _ := strconv.Atoi(Options.Retries)
+    var err error
+    for i, itemUrl := range urls {
+        fetchUrl := RewriteUrl(itemUrl)
+        if i > 0 {
+            Log.Warn("Warning: Trying the mirror", fetchUrl)
+        }
+        delay := fetchRetryDelay
+        for attempt := 0; attempt <= retries; attempt++ {
+            if attempt > 0 {
+                Log.Warn("Warning: Retrying", fetchUrl, "in", delay.String(), "after the error:", err)
+                time.Sleep(delay)
+                delay *= 2
+            }
+            // Start each attempt with an empty folder so the remote of the previous attempt is gone
+            os.RemoveAll(git.Path)
+            os.MkdirAll(git.Path, 0777)
+            err = git.InitAndCheckout(fetchUrl, commit)
+            if err == nil {
+                return itemUrl, nil;
+            }
+            if !errors.Is(err, bpmerror.Network) {
+                break
+            }
+        }
+    }
+    return "", err;
+}
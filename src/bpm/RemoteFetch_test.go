@@ -0,0 +1,57 @@
+package main;
+
+import (
+    "testing"
+)
+
+func TestApplyUrlRules(t *testing.T) {
+    rules := map[string]string{
+        "https://github.com/": "https://mirror.example.com/github/",
+        "https://github.com/acme/": "git@github.com:acme/",
+        "../": "https://git.example.com/",
+    }
+    tests := []struct {
+        name string
+        url string
+        expected string
+    }{
+        {name: "no rule", url: "https://gitlab.com/acme/lib.git", expected: "https://gitlab.com/acme/lib.git"},
+        {name: "prefix", url: "https://github.com/other/lib.git", expected: "https://mirror.example.com/github/other/lib.git"},
+        {name: "longest prefix", url: "https://github.com/acme/lib.git", expected: "git@github.com:acme/lib.git"},
+        {name: "relative url", url: "../lib.git", expected: "https://git.example.com/lib.git"},
+        {name: "only the start", url: "https://example.com/https://github.com/lib.git", expected: "https://example.com/https://github.com/lib.git"},
+        {name: "whole url", url: "https://github.com/", expected: "https://mirror.example.com/github/"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            result := ApplyUrlRules(test.url, rules)
+            if result != test.expected {
+                t.Errorf("Expected %q but got %q", test.expected, result)
+            }
+        })
+    }
+}
+
+func TestRewriteUrl(t *testing.T) {
+    defer func(config *BpmConfig) { Options.Config = config }(Options.Config)
+    tests := []struct {
+        name string
+        rules map[string]string
+        url string
+        expected string
+    }{
+        {name: "no rules", url: "https://github.com/acme/lib.git", expected: "https://github.com/acme/lib.git"},
+        {name: "config rules", rules: map[string]string{"https://github.com/": "https://mirror.example.com/"}, url: "https://github.com/acme/lib.git", expected: "https://mirror.example.com/acme/lib.git"},
+        // The rewritten url matches the rule again, so the rule must only be applied once
+        {name: "applied once", rules: map[string]string{"file:///repos/": "file:///repos/git/"}, url: "file:///repos/lib", expected: "file:///repos/git/lib"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            Options.Config = &BpmConfig{Rewrite: test.rules}
+            result := RewriteUrl(test.url)
+            if result != test.expected {
+                t.Errorf("Expected %q but got %q", test.expected, result)
+            }
+        })
+    }
+}
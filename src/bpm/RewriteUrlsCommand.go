@@ -0,0 +1,237 @@
+package main;
+
+import (
+    "os"
+    "fmt"
+    "path"
+    "bytes"
+    "strconv"
+    "strings"
+    "io/ioutil"
+    "encoding/json"
+    "path/filepath"
+    "bpmerror"
+)
+
+// Rewrites the dependency urls in the bpm.json, and with the --root= option in the bpm.json of every local module in
+// the dependency graph. Each rule replaces the start of the urls, the same as the rewrite rules in the .bpmrc file.
+// Only the urls are changed in the files so the rest of the formatting is kept.
+type RewriteUrlsCommand struct {
+    Rules map[string]string
+    Files []*RewriteUrlsFile
+    visited map[string]bool
+}
+
+type RewriteUrlsFile struct {
+    Repo string
+    File string
+    Original string
+    Rewritten string
+}
+
+func (cmd *RewriteUrlsCommand) Name() string {
+    return "rewrite-urls"
+}
+
+// The rules are the parameters after 'rewrite-urls' in the form <match>=<replace>. The rewrite rules in the .bpmrc file
+// are used when there are none.
+func (cmd *RewriteUrlsCommand) getRules() (map[string]string, error) {
+    rules := make(map[string]string)
+    index := SliceIndex(len(os.Args), func(i int) bool { return os.Args[i] == "rewrite-urls" });
+    for _, arg := range os.Args[index + 1:] {
+        if strings.Index(arg, "--") == 0 {
+            continue;
+        }
+        rule := strings.SplitN(arg, "=", 2)
+        if len(rule) != 2 || rule[0] == "" {
+            return nil, bpmerror.NewKind(bpmerror.Usage, nil, "Error: The rule " + arg + " must be in the form <match>=<replace>")
+        }
+        rules[rule[0]] = rule[1]
+    }
+    if len(rules) == 0 {
+        for match, replace := range Options.Config.Rewrite {
+            rules[match] = replace
+        }
+    }
+    if len(rules) == 0 {
+        return nil, bpmerror.NewKind(bpmerror.Usage, nil, "Error: There are no rules. Use bpm rewrite-urls <match>=<replace> or add rewrite rules to the " + Options.ConfigFileName + " file")
+    }
+    return rules, nil;
+}
+
+// The urls of the dependencies, the mirrors and the overrides
+func (cmd *RewriteUrlsCommand) getUrls(bpm *BpmData) []string {
+    urls := []string{}
+    items := []*BpmDependency{}
+    for _, name := range bpm.GetSortedKeys() {
+        items = append(items, bpm.Dependencies[name])
+    }
+    for _, item := range bpm.Overrides {
+        items = append(items, item)
+    }
+    for _, item := range items {
+        if item.Url != "" {
+            urls = append(urls, item.Url)
+        }
+        urls = append(urls, item.Mirrors...)
+    }
+    return urls;
+}
+
+// The url as a json string, the same as it is written in the bpm.json
+func (cmd *RewriteUrlsCommand) quote(value string) string {
+    var buffer bytes.Buffer
+    encoder := json.NewEncoder(&buffer)
+    encoder.SetEscapeHTML(false)
+    encoder.Encode(value)
+    return strings.TrimSpace(buffer.String());
+}
+
+// Rewrite the urls of the bpm.json in the repo and then of the local modules it requires
+func (cmd *RewriteUrlsCommand) collect(repo string) error {
+    absRepo, err := filepath.Abs(repo)
+    if err != nil {
+        absRepo = repo
+    }
+    if cmd.visited[absRepo] {
+        return nil;
+    }
+    cmd.visited[absRepo] = true
+    file := path.Join(repo, Options.BpmFileName)
+    dat, err := ioutil.ReadFile(file)
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem reading the file " + file)
+    }
+    bpm := &BpmData{}
+    err = bpm.LoadData(file, dat)
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the file " + file)
+    }
+    rewritten := string(dat)
+    for _, itemUrl := range cmd.getUrls(bpm) {
+        newUrl := ApplyUrlRules(itemUrl, cmd.Rules)
+        if newUrl == itemUrl {
+            continue;
+        }
+        if !strings.Contains(rewritten, cmd.quote(itemUrl)) && !strings.Contains(rewritten, cmd.quote(newUrl)) {
+            Log.Warn("Warning: Could not find the url", itemUrl, "in", file, "to rewrite it. Change it by hand.")
+            continue;
+        }
+        rewritten = strings.Replace(rewritten, cmd.quote(itemUrl), cmd.quote(newUrl), -1)
+    }
+    if rewritten != string(dat) {
+        cmd.Files = append(cmd.Files, &RewriteUrlsFile{Repo: repo, File: file, Original: string(dat), Rewritten: rewritten})
+    }
+
+    if Options.UseLocalPath == "" {
+        return nil;
+    }
+    // The same modules that install and update use from the --root= folder
+    for _, name := range bpm.GetSortedKeys() {
+        if strings.Index(bpm.Dependencies[name].Url, "http") != -1 {
+            continue;
+        }
+        localRepo := path.Join(Options.UseLocalPath, name)
+        if !PathExists(path.Join(localRepo, Options.BpmFileName)) {
+            continue;
+        }
+        err = cmd.collect(localRepo)
+        if err != nil {
+            return err;
+        }
+    }
+    return nil;
+}
+
+// Print the changed lines of each file. Only the urls change so the lines stay in the same place.
+func (cmd *RewriteUrlsCommand) printDiff() {
+    for _, rewriteFile := range cmd.Files {
+        fmt.Println("--- " + rewriteFile.File)
+        fmt.Println("+++ " + rewriteFile.File)
+        originalLines := strings.Split(rewriteFile.Original, "\n")
+        rewrittenLines := strings.Split(rewriteFile.Rewritten, "\n")
+        for i := range originalLines {
+            if i >= len(rewrittenLines) || originalLines[i] == rewrittenLines[i] {
+                continue;
+            }
+            fmt.Println("@@ line " + strconv.Itoa(i + 1) + " @@")
+            fmt.Println("-" + originalLines[i])
+            fmt.Println("+" + rewrittenLines[i])
+        }
+        fmt.Println("")
+    }
+}
+
+// With the --commit option each repository must be clean so the commit only contains the rewritten bpm.json
+func (cmd *RewriteUrlsCommand) checkRepos() error {
+    for _, rewriteFile := range cmd.Files {
+        git := GitExec{Path: rewriteFile.Repo}
+        if !git.IsGitRepo() {
+            return bpmerror.NewKind(bpmerror.Usage, nil, "Error: " + rewriteFile.Repo + " is not a git repository")
+        }
+        uncommitted, err := git.HasUncommittedFiles()
+        if err != nil {
+            return bpmerror.New(err, "Error: Could not get the status of the git repository " + rewriteFile.Repo)
+        }
+        if uncommitted {
+            return bpmerror.NewKind(bpmerror.Precondition, nil, "Error: There are uncommitted changes in " + rewriteFile.Repo + ". Commit or stash the changes before the urls are rewritten.")
+        }
+    }
+    return nil;
+}
+
+func (cmd *RewriteUrlsCommand) Execute() (error) {
+    err := Options.DoesBpmFileExist();
+    if err != nil {
+        return err;
+    }
+    cmd.Rules, err = cmd.getRules()
+    if err != nil {
+        return err;
+    }
+    cmd.visited = make(map[string]bool)
+    err = cmd.collect(Options.WorkingDir)
+    if err != nil {
+        return err;
+    }
+    if len(cmd.Files) == 0 {
+        Log.Info("None of the dependency urls match the rules. Done.")
+        return nil;
+    }
+    if Options.Commit {
+        err = cmd.checkRepos()
+        if err != nil {
+            return err;
+        }
+    }
+
+    cmd.printDiff()
+    if Options.DryRun {
+        Log.Info("Would rewrite the urls in", len(cmd.Files), "files")
+        return nil;
+    }
+    confirmed, err := Confirm("Rewrite the urls in " + strconv.Itoa(len(cmd.Files)) + " files?")
+    if err != nil {
+        return err;
+    }
+    if !confirmed {
+        Log.Info("Nothing was changed.")
+        return nil;
+    }
+    for _, rewriteFile := range cmd.Files {
+        err = ioutil.WriteFile(rewriteFile.File, []byte(rewriteFile.Rewritten), 0666)
+        if err != nil {
+            return bpmerror.New(err, "Error: There was an issue writing the file " + rewriteFile.File)
+        }
+        Log.Info("Rewrote the urls in", rewriteFile.File)
+        if Options.Commit {
+            git := GitExec{Path: rewriteFile.Repo}
+            err = git.Commit("Rewrite the dependency urls", []string{Options.BpmFileName})
+            if err != nil {
+                return bpmerror.New(err, "Error: Could not commit the " + Options.BpmFileName + " file in " + rewriteFile.Repo)
+            }
+            Log.Info("Committed the", Options.BpmFileName, "file in", rewriteFile.Repo)
+        }
+    }
+    return nil;
+}
@@ -0,0 +1,82 @@
+package main;
+
+import (
+    "os"
+    "bytes"
+    "os/exec"
+    "path"
+    "strings"
+    "bpmerror"
+)
+
+// Runs the lifecycle scripts from the scripts section of a bpm.json. The scripts are run with the shell in the
+// module folder and the environment describes the module.
+type ScriptExec struct {
+    Path string
+    Name string
+    Version string
+    Commit string
+}
+
+func (script *ScriptExec) Env(event string) []string {
+    modulePath := script.Path
+    if !path.IsAbs(modulePath) {
+        modulePath = path.Join(Options.WorkingDir, modulePath)
+    }
+    return []string{
+        "BPM_EVENT=" + event,
+        "BPM_MODULE_NAME=" + script.Name,
+        "BPM_MODULE_VERSION=" + script.Version,
+        "BPM_MODULE_COMMIT=" + script.Commit,
+        "BPM_MODULE_PATH=" + modulePath,
+        "BPM_ROOT=" + Options.WorkingDir,
+    }
+}
+
+func (script *ScriptExec) Run(event string, scripts map[string]string) error {
+    command := strings.TrimSpace(scripts[event])
+    if command == "" {
+        return nil;
+    }
+    if Options.IgnoreScripts {
+        Log.Info("Ignoring the", event, "script for", script.Name)
+        return nil;
+    }
+    Log.Info("Running the", event, "script for", script.Name + ":", command)
+    cmd := exec.Command("sh", "-c", command)
+    cmd.Dir = script.Path
+    cmd.Env = append(os.Environ(), script.Env(event)...)
+    // Stream the script output unless the output is quiet or json where it is logged as debug messages instead
+    var out bytes.Buffer
+    if Log.Format == "text" && Log.IsEnabled(LogLevelInfo) {
+        cmd.Stdout = os.Stdout
+        cmd.Stderr = os.Stderr
+    } else {
+        cmd.Stdout = &out
+        cmd.Stderr = &out
+    }
+    phase := Log.StartPhase(script.Name, event)
+    err := cmd.Run()
+    if out.Len() > 0 {
+        phase.Debug(out.String())
+    }
+    phase.Done()
+    if err != nil {
+        if out.Len() > 0 && !Log.IsEnabled(LogLevelDebug) {
+            phase.Error(out.String())
+        }
+        return bpmerror.New(err, "Error: The " + event + " script failed for " + script.Name)
+    }
+    return nil;
+}
+
+// Run a lifecycle script of the root project
+func RunRootScript(bpm *BpmData, event string) error {
+    if strings.TrimSpace(bpm.Scripts[event]) == "" {
+        return nil;
+    }
+    git := GitExec{Path: Options.WorkingDir}
+    commit, _ := git.GetLatestCommit()
+    script := ScriptExec{Path: Options.WorkingDir, Name: bpm.Name, Version: bpm.Version, Commit: commit}
+    return script.Run(event, bpm.Scripts)
+}
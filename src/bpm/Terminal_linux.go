@@ -0,0 +1,15 @@
+package main;
+
+import (
+    "os"
+    "unsafe"
+    "syscall"
+)
+
+// Returns true if the file is a terminal. /dev/null is a character device as well, so the terminal settings are read to
+// tell them apart.
+func IsTerminal(file *os.File) bool {
+    var termios syscall.Termios
+    _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+    return errno == 0;
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package main;
+
+import (
+    "os"
+)
+
+// Returns true if the file is a terminal. On the other platforms any character device is taken as a terminal.
+func IsTerminal(file *os.File) bool {
+    info, err := file.Stat()
+    return err == nil && info.Mode() & os.ModeCharDevice != 0;
+}
@@ -2,7 +2,6 @@ package main;
 
 import (
     "os"
-    "fmt"
     "strings"
     "path"
     "bpmerror"
@@ -29,39 +28,67 @@ func (cmd *UninstallCommand) Execute() (error) {
     if err != nil {
         return err;
     }
-    fmt.Println("Reading",Options.BpmFileName,"...")
+    Log.Debug("Reading",Options.BpmFileName,"...")
     bpm := BpmData{}
     err = bpm.LoadFile(Options.BpmFileName);
     if err != nil {
-        return bpmerror.New(err, "Error: There was a problem loading the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
     }
 
     if !bpm.HasDependencies() {
-        fmt.Println("There are no dependencies")
+        Log.Info("There are no dependencies")
         return nil;
     }
 
     uninstallModuleName := cmd.getUninstallModuleName();
     if uninstallModuleName == "" {
-        return bpmerror.New(nil, "Error: a module name must be specified")
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: a module name must be specified")
     }
 
     _, exists := bpm.Dependencies[uninstallModuleName];
     if !exists {
-        fmt.Println(uninstallModuleName, "is not a dependency")
+        Log.Warn(uninstallModuleName, "is not a dependency")
         return nil;
     }
 
+    bump := NewVersionBump(&bpm)
     delete(bpm.Dependencies, uninstallModuleName)
-    workingPath,_ := os.Getwd();
-    npm := NpmExec{Path: workingPath}
-    err = npm.Uninstall(uninstallModuleName)
+    // The module is kept when another dependency still requires it
+    names, missing, err := ResolveRequiredModules(&bpm)
     if err != nil {
-        return bpmerror.New(err, "Error: Failed to npm uninstall module " + uninstallModuleName)
+        return err;
+    }
+    if names[uninstallModuleName] {
+        Log.Info(uninstallModuleName, "is still required by another dependency so it is kept in", Options.BpmCachePath, "and node_modules")
+    } else if len(missing) > 0 {
+        Log.Warn("Warning:", uninstallModuleName, "is kept in", Options.BpmCachePath, "and node_modules since", strings.Join(missing, ", "), "is missing from", Options.BpmCachePath + ". Run bpm install to remove it.")
+    } else {
+        workingPath,_ := os.Getwd();
+        npm := NpmExec{Path: workingPath}
+        err = npm.Uninstall(uninstallModuleName)
+        if err != nil {
+            return bpmerror.NewKind(bpmerror.PackageManager, err, "Error: Failed to npm uninstall module " + uninstallModuleName)
+        }
+        itemPath := path.Join(Options.BpmCachePath, uninstallModuleName);
+        os.RemoveAll(itemPath)
+        installed, err := LoadInstalledPackages()
+        if err != nil {
+            return err;
+        }
+        delete(installed.Packages, uninstallModuleName)
+        err = installed.Save()
+        if err != nil {
+            return err;
+        }
+    }
+    _, err = bump.Apply(&bpm);
+    if err != nil {
+        return err;
+    }
+    err = bpm.WriteFile(path.Join(Options.WorkingDir, Options.BpmFileName));
+    if err != nil {
+        return err;
     }
-    itemPath := path.Join(Options.BpmCachePath, uninstallModuleName);
-    os.RemoveAll(itemPath)
-    bpm.IncrementVersion();
-    bpm.WriteFile(path.Join(workingPath, Options.BpmFileName));
-    return nil;
+    // The modules that were only required by the uninstalled module are no longer required
+    return PruneNodeModules(&bpm);
 }
@@ -1,7 +1,6 @@
 package main;
 
 import (
-    "fmt"
     "os"
     "path"
     "strings"
@@ -42,22 +41,69 @@ func DetermineLocalCommitValue(source string) (string, error) {
     return commit, nil
 }
 
+// With the --commit option the updated bpm.json of a local module is committed before its parent is pinned to it, so the
+// parent gets the commit that contains the change. The modules are processed after their own dependencies, so the
+// commits are made from the bottom of the graph up. The --tag option tags each commit with v<version>.
+func commitLocalModule(name string, source string) error {
+    if !Options.Commit {
+        return nil;
+    }
+    git := GitExec{Path: source}
+    changedFiles, err := git.GetChangedFiles()
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not get the status of the git repository " + source)
+    }
+    for _, file := range changedFiles {
+        if file != Options.BpmFileName {
+            return bpmerror.NewKind(bpmerror.Precondition, nil, "Error: There are uncommitted changes in " + source + ". Commit or stash the changes before the update.")
+        }
+    }
+    if len(changedFiles) == 0 {
+        return nil;
+    }
+    moduleBpm, err := LoadBpmData(source)
+    if err != nil {
+        return err;
+    }
+    tag := "v" + moduleBpm.Version
+    if Options.Tag && git.TagExists(tag) {
+        return bpmerror.NewKind(bpmerror.Precondition, nil, "Error: The tag " + tag + " already exists in " + source)
+    }
+    err = git.Commit("Update the dependencies of " + moduleBpm.Name + " (" + moduleBpm.Version + ")", []string{Options.BpmFileName})
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not commit the " + Options.BpmFileName + " file of " + name)
+    }
+    Log.Info("Committed the", Options.BpmFileName, "file of", name, "version", moduleBpm.Version)
+    if Options.Tag {
+        err = git.Tag(tag, "Release " + tag)
+        if err != nil {
+            return bpmerror.New(err, "Error: Could not create the tag " + tag + " for " + name)
+        }
+        Log.Info("Tagged", name, "with", tag)
+    }
+    return nil;
+}
+
 func updateRecursiveLocalItems(itemProcessed *ItemProcessed) error {
     // Only update and save the bpm if the recursive option is set.
     if !Options.Recursive || !itemProcessed.Local {
         return nil;
     }
+    err := commitLocalModule(itemProcessed.Name, itemProcessed.Source)
+    if err != nil {
+        return err;
+    }
     commit, err := DetermineLocalCommitValue(itemProcessed.Source)
     if err != nil {
         return bpmerror.New(err, "Error: There was an issue getting the latest commit for " + itemProcessed.Name)
     }
-    newItem := &BpmDependency{Url: itemProcessed.Item.Url, Commit:commit}
+    newItem := &BpmDependency{Url: itemProcessed.Item.Url, Commit:commit, Branch: itemProcessed.Item.Branch, Exclude: itemProcessed.Item.Exclude, Mirrors: itemProcessed.Item.Mirrors, Group: itemProcessed.Item.Group}
     existingItem := itemProcessed.Bpm.Dependencies[itemProcessed.Name];
     if !existingItem.Equal(newItem) {
+        bump := NewVersionBump(itemProcessed.Bpm)
         itemProcessed.Bpm.Dependencies[itemProcessed.Name] = newItem;
         filePath := path.Join(Options.UseLocalPath, itemProcessed.Bpm.Name, Options.BpmFileName);
-        cachePath := path.Join(itemProcessed.Cache, Options.BpmFileName);
-        err = itemProcessed.Bpm.IncrementVersion();
+        _, err = bump.Apply(itemProcessed.Bpm);
         if err != nil {
             return err;
         }
@@ -65,7 +111,104 @@ func updateRecursiveLocalItems(itemProcessed *ItemProcessed) error {
         if err != nil {
             return err;
         }
-        err = itemProcessed.Bpm.WriteFile(cachePath)
+        // Refresh the copy of the parent in the bpm cache. A linked copy is the source folder that was just written.
+        cacheFolder := path.Join(Options.BpmCachePath, itemProcessed.Bpm.Name, Options.LocalModuleName)
+        if PathExists(cacheFolder) && !IsLink(cacheFolder) {
+            err = itemProcessed.Bpm.WriteFile(path.Join(cacheFolder, Options.BpmFileName))
+            if err != nil {
+                return err;
+            }
+        }
+    }
+    return nil;
+}
+
+// Update the dependency to the latest commit of its branch, or to the local folder with the --root option, and
+// process its dependencies. The bpm.json is only changed when the dependency was updated.
+func (cmd *UpdateCommand) updateDependency(bpm *BpmData, updateModule string) (error) {
+    depItem := bpm.Dependencies[updateModule]
+    // An override forces the commit, so the dependency is installed at that commit the same as with install and its
+    // entry in the bpm.json is kept
+    if overrideItem, override := dependencyOverrides.Apply(updateModule, &dependencyPath, depItem); override != "" {
+        Log.Info("The override", override, "uses", updateModule, "@", overrideItem.Commit, "so it is not updated")
+        return processDependency(bpm, updateModule, "", nil);
+    }
+    skip, err := dependencyPath.CheckCycle(updateModule, depItem.Commit)
+    if err != nil || skip {
+        return err;
+    }
+    if Options.UseLocalPath != "" && strings.Index(depItem.Url, "http") == -1 {
+        moduleSourceUrl := path.Join(Options.UseLocalPath, updateModule);
+        Log.Info("Processing local dependency in", moduleSourceUrl)
+        commit, err := DetermineLocalCommitValue(moduleSourceUrl)
+        if err != nil {
+            return bpmerror.New(err, "Error: There was an issue getting the latest commit for " + updateModule)
+        }
+        moduleBpm, cacheItem, err := ProcessLocalModule(moduleSourceUrl)
+        if err != nil {
+            return err;
+        }
+        // The name in the bpm.json may be different than the dependency name
+        skip, err = dependencyPath.CheckCycle(moduleBpm.Name, Options.LocalModuleName)
+        if err != nil || skip {
+            return err;
+        }
+        moduleCache.Add(cacheItem)
+        dependencyGraph.Add(&DependencyNode{Name: updateModule, Commit: Options.LocalModuleName, Version: moduleBpm.Version, Url: depItem.Url, Source: moduleSourceUrl, Cache: cacheItem.Path, Local: true, Parents: []string{bpm.Name}})
+        dependencyPath.PushExcluding(moduleBpm.Name, Options.LocalModuleName, depItem.Exclude)
+        err = ProcessDependencies(moduleBpm, "", updateRecursiveLocalItems)
+        dependencyPath.Pop()
+        if err != nil {
+            return err;
+        }
+        if Options.Commit {
+            // Pin the dependency to the commit of its updated bpm.json
+            err = commitLocalModule(updateModule, moduleSourceUrl)
+            if err != nil {
+                return err;
+            }
+            commit, err = DetermineLocalCommitValue(moduleSourceUrl)
+            if err != nil {
+                return bpmerror.New(err, "Error: There was an issue getting the latest commit for " + updateModule)
+            }
+        }
+
+        newItem := &BpmDependency{Url: depItem.Url, Commit:commit, Branch: depItem.Branch, Exclude: depItem.Exclude, Mirrors: depItem.Mirrors, Group: depItem.Group}
+        bpm.Dependencies[updateModule] = newItem;
+
+    } else {
+
+        itemRemoteUrl, err := MakeRemoteUrl(depItem.Url)
+        if err != nil {
+            return err;
+        }
+        mirrorUrls, err := MakeMirrorUrls(depItem.Mirrors)
+        if err != nil {
+            return err;
+        }
+        moduleBpm, cacheItem, err := ProcessRemoteModule(itemRemoteUrl, mirrorUrls, "", depItem.GetBranch())
+        if err != nil {
+            return err;
+        }
+        skip, err = dependencyPath.CheckCycle(moduleBpm.Name, cacheItem.Commit)
+        if err != nil || skip {
+            return err;
+        }
+        moduleCache.Add(cacheItem)
+        dependencyGraph.Add(&DependencyNode{Name: updateModule, Commit: cacheItem.Commit, Version: moduleBpm.Version, Url: depItem.Url, Source: cacheItem.Path, Cache: cacheItem.Path, Local: false, Parents: []string{bpm.Name}})
+        dependencyPath.PushExcluding(moduleBpm.Name, cacheItem.Commit, depItem.Exclude)
+        if Options.UseParentUrl {
+            err = ProcessDependencies(moduleBpm, itemRemoteUrl, nil)
+        } else {
+            err = ProcessDependencies(moduleBpm, "", nil)                
+        }
+        dependencyPath.Pop()
+        if err != nil {
+            return err;
+        }
+        // Only save the branch in the bpm.json when one was explicitly requested so the default branch is detected otherwise
+        newItem := &BpmDependency{Url: depItem.Url, Commit:cacheItem.Commit, Branch: depItem.GetBranch(), Exclude: depItem.Exclude, Mirrors: depItem.Mirrors, Group: depItem.Group}
+        bpm.Dependencies[updateModule] = newItem;
     }
     return nil;
 }
@@ -78,83 +221,80 @@ func (cmd *UpdateCommand) Execute() (error) {
     bpm := BpmData{};
     err = bpm.LoadFile(Options.BpmFileName);
     if err != nil {
-        return bpmerror.New(nil, "Error: There was a problem loading the bpm.json file")
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
     }
     if !bpm.HasDependencies() {
-        fmt.Println("There are no dependencies. Done.")
+        Log.Info("There are no dependencies. Done.")
         return nil;
     }
 
+    err = RunRootScript(&bpm, "preupdate")
+    if err != nil {
+        return err;
+    }
+    dependencyOverrides.Load(&bpm)
+    bump := NewVersionBump(&bpm)
     bpmModuleName := cmd.getUpdateModuleName()
     if bpmModuleName != "" && !bpm.HasDependency(bpmModuleName) {
-        return bpmerror.New(err, "Error: Could not find module " + bpmModuleName + " in the dependencies")
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: Could not find module " + bpmModuleName + " in the dependencies")
     }
 
+    // The dependencies of the root project are processed below the root, so their dev dependencies are not installed
+    dependencyPath.Push(bpm.Name, "")
+    defer dependencyPath.Pop()
     // Always process the keys sorted by name so the installation is consistent
     sortedKeys := bpm.GetSortedKeys();
     for _, updateModule := range sortedKeys {
-        depItem := bpm.Dependencies[updateModule]
         // If a specific module name was specified then skip the others.
         if bpmModuleName != "" && bpmModuleName != updateModule {
             continue;
         }
-        if Options.UseLocalPath != "" && strings.Index(depItem.Url, "http") == -1 {
-            moduleSourceUrl := path.Join(Options.UseLocalPath, updateModule);
-            fmt.Println("Processing local dependency in", moduleSourceUrl)
-            commit, err := DetermineLocalCommitValue(moduleSourceUrl)
-            if err != nil {
-                return bpmerror.New(err, "Error: There was an issue getting the latest commit for " + updateModule)
-            }
-            moduleBpm, cacheItem, err := ProcessLocalModule(moduleSourceUrl)
-            if err != nil {
-                return err;
-            }
-            moduleCache.Add(cacheItem)
-            err = ProcessDependencies(moduleBpm, "", updateRecursiveLocalItems)
-            if err != nil {
-                return err;
-            }
-
-            newItem := &BpmDependency{Url: depItem.Url, Commit:commit}
-            bpm.Dependencies[updateModule] = newItem;
-
-        } else {
-
-            itemRemoteUrl, err := MakeRemoteUrl(depItem.Url)
-            if err != nil {
-                return err;
-            }
-            moduleBpm, cacheItem, err := ProcessRemoteModule(itemRemoteUrl, "master")
-            if err != nil {
-                return err;
-            }
-            moduleCache.Add(cacheItem)
-            if Options.UseParentUrl {
-                err = ProcessDependencies(moduleBpm, itemRemoteUrl, nil)
-            } else {
-                err = ProcessDependencies(moduleBpm, "", nil)                
-            }
-            if err != nil {
-                return err;
-            }
-            newItem := &BpmDependency{Url: depItem.Url, Commit:cacheItem.Commit}
-            bpm.Dependencies[updateModule] = newItem;
+        if bpm.Dependencies[updateModule].IsOptional() {
+            ProcessOptionalDependency(updateModule, func() error {
+                return cmd.updateDependency(&bpm, updateModule)
+            })
+            continue;
         }
-    }
-    moduleCache.Trim();
-    if !Options.SkipNpmInstall {
-        err = moduleCache.Install()
+        err = cmd.updateDependency(&bpm, updateModule)
         if err != nil {
-            return bpmerror.New(err, "Error: There was an issue performing npm install on the dependencies")
+            return err;
         }
     }
-    err = bpm.IncrementVersion();
+    dependencyGraph.LogSummary();
+    dependencyGraph.WarnExcluded();
+    moduleCache.Trim();
+    // The preupdate and postupdate scripts of the dependencies run around the install of the modules that changed
+    updatedModules, err := moduleCache.UpdatedModules()
     if err != nil {
         return err;
     }
-    err = bpm.WriteFile(path.Join(Options.WorkingDir, Options.BpmFileName));
+    err = updatedModules.RunScripts("preupdate")
     if err != nil {
         return err;
     }
-    return nil;
+    err = moduleCache.InstallAndRunScripts()
+    if err != nil {
+        return bpmerror.New(err, "Error: There was an issue performing npm install on the dependencies")
+    }
+    err = updatedModules.RunScripts("postupdate")
+    if err != nil {
+        return err;
+    }
+    changed, err := bump.Apply(&bpm);
+    if err != nil {
+        return err;
+    }
+    if changed {
+        err = bpm.WriteFile(path.Join(Options.WorkingDir, Options.BpmFileName));
+        if err != nil {
+            return err;
+        }
+    }
+    if !Options.SkipNpmInstall {
+        err = PruneNodeModules(&bpm)
+        if err != nil {
+            return err;
+        }
+    }
+    return RunRootScript(&bpm, "postupdate");
 }
@@ -0,0 +1,90 @@
+package main;
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "path/filepath"
+)
+
+func TestUpdateOptional(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    commit := commitFiles(t, filepath.Join(temp, "git", "a"), map[string]string{"bpm.json": `{"name": "a", "version": "1.0.0", "dependencies": {}}`})
+    project := filepath.Join(temp, "project")
+    defer useUpdateProject(t, temp, `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a", "commit": "aaaaaaa"}}, "optionalDependencies": {"opt": {"url": "../opt", "commit": "bbbbbbb"}}}`)()
+    cmd := &UpdateCommand{}
+    err := cmd.Execute()
+    if err != nil {
+        t.Fatalf("Expected the optional dependency that cannot be fetched to only be a warning but got %v", err)
+    }
+    bpm := loadManifest(t, project)
+    if bpm.Dependencies["a"].Commit != commit {
+        t.Errorf("Expected a to be updated to %s but got %s", commit, bpm.Dependencies["a"].Commit)
+    }
+    if bpm.Dependencies["opt"].Commit != "bbbbbbb" {
+        t.Errorf("Expected the optional dependency to keep its commit but got %s", bpm.Dependencies["opt"].Commit)
+    }
+    if _, exists := moduleCache.Items["opt"]; exists {
+        t.Errorf("Expected the optional dependency to not be in the module cache")
+    }
+}
+
+// Create a project that requires the modules in the git folder and use it for the test
+func useUpdateProject(t *testing.T, temp string, manifest string) func() {
+    project := filepath.Join(temp, "project")
+    writeFiles(t, project, map[string]string{"bpm.json": manifest})
+    restoreProject := useProject(t, project)
+    restoreResolution := useResolution(t, "update")
+    Options.Config = &BpmConfig{}
+    Options.UseRemoteUrl = "file://" + filepath.Join(temp, "git", "project")
+    Options.Retries = "0"
+    Options.SkipNpmInstall = true
+    return func() {
+        restoreResolution()
+        restoreProject()
+    };
+}
+
+func TestUpdateOverride(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    repo := filepath.Join(temp, "git", "a")
+    first := commitFiles(t, repo, map[string]string{"bpm.json": `{"name": "a", "version": "1.0.0", "dependencies": {}}`})
+    second := commitFiles(t, repo, map[string]string{"bpm.json": `{"name": "a", "version": "1.1.0", "dependencies": {}}`})
+    defer useUpdateProject(t, temp, `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a", "commit": "` + second + `"}}, "overrides": {"a": {"commit": "` + first + `"}}}`)()
+    cmd := &UpdateCommand{}
+    err := cmd.Execute()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if moduleCache.Items["a"] == nil || moduleCache.Items["a"].Commit != first {
+        t.Errorf("Expected a to be installed at the commit of the override %s", first)
+    }
+    bpm := loadManifest(t, filepath.Join(temp, "project"))
+    if bpm.Dependencies["a"].Commit != second {
+        t.Errorf("Expected the dependency to keep the commit %s but got %s", second, bpm.Dependencies["a"].Commit)
+    }
+}
+
+func TestUpdateCycle(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    // The module has the same name as the project, so it closes a cycle
+    commitFiles(t, filepath.Join(temp, "git", "a"), map[string]string{"bpm.json": `{"name": "app", "version": "1.0.0", "dependencies": {}}`})
+    defer useUpdateProject(t, temp, `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a", "commit": "aaaaaaa"}}}`)()
+    cmd := &UpdateCommand{}
+    err := cmd.Execute()
+    if err == nil || !strings.Contains(err.Error(), "There is a cycle in the dependencies app → app@") {
+        t.Fatalf("Expected the cycle to be reported but got %v", err)
+    }
+    Options.Cycles = "break"
+    err = cmd.Execute()
+    if err != nil {
+        t.Fatalf("Expected the cycle to be ignored with --cycles=break but got %v", err)
+    }
+    bpm := loadManifest(t, filepath.Join(temp, "project"))
+    if bpm.Dependencies["a"].Commit != "aaaaaaa" {
+        t.Errorf("Expected the dependency that closes the cycle to not be updated but got %s", bpm.Dependencies["a"].Commit)
+    }
+}
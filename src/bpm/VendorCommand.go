@@ -0,0 +1,299 @@
+package main;
+
+import (
+    "io"
+    "os"
+    "path"
+    "strings"
+    "io/ioutil"
+    "archive/tar"
+    "compress/gzip"
+    "encoding/json"
+    "path/filepath"
+    "bpmerror"
+)
+
+/*
+The manifest in the root of the vendor folder describes the resolved dependency graph
+{
+    "name": "my-component",
+    "version": "1.0.0",
+    "modules": [
+        {
+            "name": "my-dependency-1",
+            "commit": "90b0a2da501451cf55ee07f9faeb3f8707af6011",
+            "version": "1.0.3",
+            "url": "../my-dependency-1.git",
+            "parents": ["my-component"]
+        }
+    ]
+}
+*/
+
+type VendorModule struct {
+    Name string `json:"name"`
+    Commit string `json:"commit"`
+    Version string `json:"version"`
+    Url string `json:"url"`
+    Parents []string `json:"parents"`
+}
+
+type VendorManifest struct {
+    Name string `json:"name"`
+    Version string `json:"version"`
+    Modules []*VendorModule `json:"modules"`
+}
+
+const vendorManifestName = "bpm-vendor.json"
+
+// Exports the resolved dependency graph with the source of every module at its commit, so the modules can be installed
+// without access to the git repositories
+type VendorCommand struct {
+}
+
+func (cmd *VendorCommand) Name() string {
+    return "vendor"
+}
+
+func (cmd *VendorCommand) getVendorPath() string {
+    index := SliceIndex(len(os.Args), func(i int) bool { return os.Args[i] == "vendor" });
+    if len(os.Args) > index + 1 && strings.Index(os.Args[index + 1], "--") != 0 {
+        return os.Args[index + 1];
+    }
+    return "bpm_vendor";
+}
+
+func isTarball(file string) bool {
+    return strings.HasSuffix(file, ".tar.gz") || strings.HasSuffix(file, ".tgz");
+}
+
+func (cmd *VendorCommand) Execute() (error) {
+    err := Options.DoesBpmFileExist();
+    if err != nil {
+        return err;
+    }
+    bpm := &BpmData{}
+    err = bpm.LoadFile(Options.BpmFileName);
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
+    }
+    err = bpm.Validate();
+    if err != nil {
+        return err;
+    }
+    Options.EnsureBpmCacheFolder();
+    dependencyOverrides.Load(bpm)
+    Log.Info("Processing all dependencies for", bpm.Name, "version", bpm.Version);
+    err = ProcessDependencies(bpm, "", nil)
+    if err != nil {
+        return err;
+    }
+    dependencyGraph.WarnExcluded();
+
+    vendorPath := cmd.getVendorPath()
+    folder := vendorPath
+    if isTarball(vendorPath) {
+        folder = path.Join(Options.BpmCachePath, "xx_vendor_xx")
+        defer os.RemoveAll(folder)
+    }
+    os.RemoveAll(folder)
+    manifest := &VendorManifest{Name: bpm.Name, Version: bpm.Version, Modules: []*VendorModule{}}
+    for _, key := range dependencyGraph.SortedKeys() {
+        node := dependencyGraph.Nodes[key]
+        modulePath := path.Join(Options.BpmCachePath, node.Name, node.Commit)
+        if node.Local || !PathExists(modulePath) {
+            return bpmerror.NewKind(bpmerror.LocalCommit, nil, "Error: The module " + node.Name + " uses a local folder. Only the modules at a commit can be vendored. Remove " + path.Join(Options.BpmCachePath, node.Name, Options.LocalModuleName) + " and run bpm vendor again.")
+        }
+        Log.Info("Vendoring", key)
+        copyDir := CopyDir{Exclude: ".git"}
+        err = copyDir.Copy(modulePath, path.Join(folder, node.Name, node.Commit))
+        if err != nil {
+            return err;
+        }
+        manifest.Modules = append(manifest.Modules, &VendorModule{Name: node.Name, Commit: node.Commit, Version: node.Version, Url: node.Url, Parents: node.Parents})
+    }
+    dat, err := json.MarshalIndent(manifest, "", "    ")
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem writing the vendor manifest")
+    }
+    err = ioutil.WriteFile(path.Join(folder, vendorManifestName), dat, 0666)
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem writing the vendor manifest")
+    }
+    if isTarball(vendorPath) {
+        err = writeTarball(folder, vendorPath)
+        if err != nil {
+            return err;
+        }
+    }
+    Log.Info("Vendored", len(manifest.Modules), "modules to", vendorPath)
+    return nil;
+}
+
+// Prepare the --from-vendor= option. A tarball is extracted to a temporary folder which is removed by the returned function.
+func OpenVendor() (func(), error) {
+    cleanup := func() {}
+    if Options.FromVendor == "" {
+        return cleanup, nil;
+    }
+    if isTarball(Options.FromVendor) {
+        folder := path.Join(Options.BpmCachePath, "xx_vendor_xx")
+        os.RemoveAll(folder)
+        err := extractTarball(Options.FromVendor, folder)
+        if err != nil {
+            os.RemoveAll(folder)
+            return cleanup, err;
+        }
+        Options.FromVendor = folder
+        cleanup = func() { os.RemoveAll(folder) }
+    }
+    if !PathExists(path.Join(Options.FromVendor, vendorManifestName)) {
+        cleanup()
+        return func() {}, bpmerror.NewKind(bpmerror.Usage, nil, "Error: " + Options.FromVendor + " is not a vendor folder. The " + vendorManifestName + " file does not exist.")
+    }
+    return cleanup, nil;
+}
+
+// Copy a module from the vendor folder to the bpm cache
+func CopyFromVendor(name string, commit string, destination string) error {
+    if commit == Options.LocalModuleName {
+        return bpmerror.NewKind(bpmerror.LocalCommit, nil, "Error: The commit hash is specified as 'local' for dependency " + name + ". Please finalize the commit hash for this dependency.")
+    }
+    source := path.Join(Options.FromVendor, name, commit)
+    if !PathExists(source) {
+        return bpmerror.NewKind(bpmerror.CommitNotFound, nil, "Error: The module " + name + " @ " + commit + " is not in the vendor folder " + Options.FromVendor)
+    }
+    copyDir := CopyDir{}
+    err := copyDir.Copy(source, destination)
+    if err != nil {
+        os.RemoveAll(destination)
+        return err;
+    }
+    return nil;
+}
+
+func writeTarball(folder string, file string) error {
+    out, err := os.Create(file)
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not create the file " + file)
+    }
+    gz := gzip.NewWriter(out)
+    tw := tar.NewWriter(gz)
+    err = filepath.Walk(folder, func(file string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err;
+        }
+        name, _ := filepath.Rel(folder, file)
+        if name == "." {
+            return nil;
+        }
+        link := ""
+        if info.Mode() & os.ModeSymlink != 0 {
+            link, err = os.Readlink(file)
+            if err != nil {
+                return err;
+            }
+        }
+        header, err := tar.FileInfoHeader(info, link)
+        if err != nil {
+            return err;
+        }
+        header.Name = filepath.ToSlash(name)
+        err = tw.WriteHeader(header)
+        if err != nil {
+            return err;
+        }
+        if !info.Mode().IsRegular() {
+            return nil;
+        }
+        f, err := os.Open(file)
+        if err != nil {
+            return err;
+        }
+        defer f.Close()
+        _, err = io.Copy(tw, f)
+        return err;
+    })
+    // The end of the tarball is only written when the writers are closed, so their errors mean the file is truncated
+    if closeErr := tw.Close(); err == nil {
+        err = closeErr
+    }
+    if closeErr := gz.Close(); err == nil {
+        err = closeErr
+    }
+    if closeErr := out.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        return bpmerror.New(err, "Error: There was a problem writing the file " + file)
+    }
+    return nil;
+}
+
+func extractTarball(file string, folder string) error {
+    in, err := os.Open(file)
+    if err != nil {
+        return bpmerror.New(err, "Error: Could not open the file " + file)
+    }
+    defer in.Close()
+    gz, err := gzip.NewReader(in)
+    if err != nil {
+        return bpmerror.New(err, "Error: The file " + file + " is not a tarball")
+    }
+    tr := tar.NewReader(gz)
+    for {
+        header, err := tr.Next()
+        if err == io.EOF {
+            return nil;
+        }
+        if err != nil {
+            return bpmerror.New(err, "Error: There was a problem reading the file " + file)
+        }
+        target := filepath.Join(folder, filepath.FromSlash(header.Name))
+        if !isInsideFolder(folder, target) {
+            return bpmerror.New(nil, "Error: The file " + file + " contains the path " + header.Name + " that is outside of the vendor folder")
+        }
+        // Nothing is written through a link, so a link in the tarball cannot be used to write outside of the folder
+        if hasLinkInPath(folder, target) {
+            return bpmerror.New(nil, "Error: The file " + file + " contains the path " + header.Name + " that would be written through a link")
+        }
+        switch header.Typeflag {
+        case tar.TypeDir:
+            err = os.MkdirAll(target, os.FileMode(header.Mode))
+        case tar.TypeSymlink:
+            linkTarget := filepath.FromSlash(header.Linkname)
+            if filepath.IsAbs(linkTarget) || !isInsideFolder(folder, filepath.Join(filepath.Dir(target), linkTarget)) {
+                return bpmerror.New(nil, "Error: The file " + file + " contains the link " + header.Name + " to " + header.Linkname + " that is outside of the vendor folder")
+            }
+            os.MkdirAll(filepath.Dir(target), 0777)
+            err = os.Symlink(header.Linkname, target)
+        case tar.TypeReg:
+            os.MkdirAll(filepath.Dir(target), 0777)
+            var out *os.File
+            out, err = os.OpenFile(target, os.O_CREATE | os.O_TRUNC | os.O_WRONLY, os.FileMode(header.Mode))
+            if err == nil {
+                _, err = io.Copy(out, tr)
+                out.Close()
+            }
+        }
+        if err != nil {
+            return bpmerror.New(err, "Error: There was a problem extracting " + header.Name + " from " + file)
+        }
+    }
+}
+
+// Returns true if the path is inside of the folder
+func isInsideFolder(folder string, file string) bool {
+    return strings.HasPrefix(filepath.Clean(file), filepath.Clean(folder) + string(os.PathSeparator));
+}
+
+// Returns true if the path or one of its parents below the folder is a link
+func hasLinkInPath(folder string, file string) bool {
+    folder = filepath.Clean(folder)
+    for current := filepath.Clean(file); current != folder && isInsideFolder(folder, current); current = filepath.Dir(current) {
+        if IsLink(current) {
+            return true;
+        }
+    }
+    return false;
+}
@@ -0,0 +1,168 @@
+package main;
+
+import (
+    "os"
+    "testing"
+    "io/ioutil"
+    "archive/tar"
+    "compress/gzip"
+    "path/filepath"
+)
+
+type tarEntry struct {
+    name string
+    link string
+    body string
+    dir bool
+}
+
+func makeTarball(t *testing.T, file string, entries []tarEntry) {
+    out, err := os.Create(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer out.Close()
+    gz := gzip.NewWriter(out)
+    tw := tar.NewWriter(gz)
+    for _, entry := range entries {
+        header := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.body))}
+        if entry.dir {
+            header = &tar.Header{Name: entry.name, Mode: 0755, Typeflag: tar.TypeDir}
+        } else if entry.link != "" {
+            header = &tar.Header{Name: entry.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: entry.link}
+        }
+        err = tw.WriteHeader(header)
+        if err != nil {
+            t.Fatal(err)
+        }
+        _, err = tw.Write([]byte(entry.body))
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err = tw.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if err = gz.Close(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestExtractTarball(t *testing.T) {
+    tests := []struct {
+        name string
+        entries []tarEntry
+        fails bool
+        files map[string]string
+    }{
+        {
+            name: "files and folders",
+            entries: []tarEntry{{name: "lib", dir: true}, {name: "lib/index.js", body: "index"}, {name: "package.json", body: "{}"}},
+            files: map[string]string{"lib/index.js": "index", "package.json": "{}"},
+        },
+        {
+            name: "file without its folder",
+            entries: []tarEntry{{name: "dist/lib/main.js", body: "main"}},
+            files: map[string]string{"dist/lib/main.js": "main"},
+        },
+        {
+            name: "link inside of the folder",
+            entries: []tarEntry{{name: "lib/index.js", body: "index"}, {name: "main.js", link: "lib/index.js"}},
+            files: map[string]string{"main.js": "index"},
+        },
+        {
+            name: "path outside of the folder",
+            entries: []tarEntry{{name: "../evil.js", body: "evil"}},
+            fails: true,
+        },
+        {
+            name: "path that leaves the folder in the middle",
+            entries: []tarEntry{{name: "lib/../../evil.js", body: "evil"}},
+            fails: true,
+        },
+        {
+            name: "absolute link",
+            entries: []tarEntry{{name: "passwd", link: "/etc/passwd"}},
+            fails: true,
+        },
+        {
+            name: "link outside of the folder",
+            entries: []tarEntry{{name: "lib/up", link: "../../.."}},
+            fails: true,
+        },
+        {
+            name: "file written through a link",
+            entries: []tarEntry{{name: "lib", dir: true}, {name: "alias", link: "lib"}, {name: "alias/index.js", body: "index"}},
+            fails: true,
+        },
+        {
+            name: "file replacing a link",
+            entries: []tarEntry{{name: "lib/index.js", body: "index"}, {name: "main.js", link: "lib/index.js"}, {name: "main.js", body: "main"}},
+            fails: true,
+        },
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            temp, err := ioutil.TempDir("", "bpm-tarball")
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer os.RemoveAll(temp)
+            file := filepath.Join(temp, "module.tar.gz")
+            folder := filepath.Join(temp, "vendor", "module")
+            makeTarball(t, file, test.entries)
+            err = extractTarball(file, folder)
+            if test.fails {
+                if err == nil {
+                    t.Fatalf("Expected the tarball to be rejected")
+                }
+                if PathExists(filepath.Join(temp, "evil.js")) || PathExists(filepath.Join(temp, "vendor", "evil.js")) {
+                    t.Errorf("Expected nothing to be written outside of the folder")
+                }
+                return;
+            }
+            if err != nil {
+                t.Fatalf("Expected the tarball to be extracted but got %v", err)
+            }
+            for name, expected := range test.files {
+                dat, err := ioutil.ReadFile(filepath.Join(folder, filepath.FromSlash(name)))
+                if err != nil {
+                    t.Fatalf("Expected the file %s to be extracted but got %v", name, err)
+                }
+                if string(dat) != expected {
+                    t.Errorf("Expected the file %s to contain %q but got %q", name, expected, string(dat))
+                }
+            }
+        })
+    }
+}
+
+// A tarball written from a folder is extracted to the same files and links
+func TestWriteTarball(t *testing.T) {
+    temp, err := ioutil.TempDir("", "bpm-tarball")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(temp)
+    source := filepath.Join(temp, "source")
+    os.MkdirAll(filepath.Join(source, "lib"), 0777)
+    ioutil.WriteFile(filepath.Join(source, "lib", "index.js"), []byte("index"), 0666)
+    os.Symlink("lib/index.js", filepath.Join(source, "main.js"))
+    file := filepath.Join(temp, "module.tar.gz")
+    err = writeTarball(source, file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    folder := filepath.Join(temp, "extracted")
+    err = extractTarball(file, folder)
+    if err != nil {
+        t.Fatal(err)
+    }
+    dat, err := ioutil.ReadFile(filepath.Join(folder, "lib", "index.js"))
+    if err != nil || string(dat) != "index" {
+        t.Errorf("Expected lib/index.js to be extracted but got %q %v", string(dat), err)
+    }
+    if !IsLink(filepath.Join(folder, "main.js")) {
+        t.Errorf("Expected main.js to be extracted as a link")
+    }
+}
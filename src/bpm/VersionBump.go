@@ -0,0 +1,109 @@
+package main;
+
+import (
+    "path"
+    "github.com/blang/semver"
+)
+
+// Determines how much the version in a bpm.json is incremented after its dependencies are changed.
+// A snapshot of the dependencies is taken before the change so the bump can be skipped when nothing changed.
+type VersionBump struct {
+    Previous map[string]*BpmDependency
+}
+
+func NewVersionBump(bpm *BpmData) *VersionBump {
+    previous := make(map[string]*BpmDependency)
+    for name, dep := range bpm.Dependencies {
+        copied := *dep
+        previous[name] = &copied
+    }
+    return &VersionBump{Previous: previous}
+}
+
+func (vb *VersionBump) HasChanges(bpm *BpmData) bool {
+    if len(vb.Previous) != len(bpm.Dependencies) {
+        return true;
+    }
+    for name, dep := range bpm.Dependencies {
+        previous, exists := vb.Previous[name]
+        if !exists || !previous.Equal(dep) {
+            return true;
+        }
+    }
+    return false;
+}
+
+// Increment the version of the bpm using the bump policy. Returns false when the dependencies are unchanged and the
+// version was left alone.
+func (vb *VersionBump) Apply(bpm *BpmData) (bool, error) {
+    if !vb.HasChanges(bpm) {
+        Log.Info("The dependencies of", bpm.Name, "did not change. The version is not incremented.")
+        return false, nil;
+    }
+    level := Options.Bump
+    if level == "auto" {
+        level = vb.InferLevel(bpm)
+    }
+    err := bpm.IncrementVersion(level)
+    if err != nil {
+        return false, err;
+    }
+    if level != "none" {
+        Log.Info("Incremented the", level, "version of", bpm.Name, "to", bpm.Version)
+    }
+    return true, nil;
+}
+
+// A major change in a dependency is a minor change for the parent. Any other change is a patch change.
+func (vb *VersionBump) InferLevel(bpm *BpmData) string {
+    level := "patch"
+    for name, dep := range bpm.Dependencies {
+        previous, exists := vb.Previous[name]
+        if !exists || previous.Commit == dep.Commit {
+            continue;
+        }
+        oldVersion, err := vb.getVersion(name, previous.Commit)
+        if err != nil {
+            continue;
+        }
+        newVersion, err := vb.getVersion(name, dep.Commit)
+        if err != nil {
+            continue;
+        }
+        if newVersion.Major > oldVersion.Major {
+            Log.Info("The major version of", name, "changed from", oldVersion.String(), "to", newVersion.String())
+            level = "minor"
+        }
+    }
+    return level;
+}
+
+// Read the version of a dependency at a commit. The bpm cache is checked first and then the git history of the
+// cached module or the local module.
+func (vb *VersionBump) getVersion(name string, commit string) (semver.Version, error) {
+    moduleBpm := &BpmData{}
+    err := moduleBpm.LoadDependencyFile(path.Join(Options.BpmCachePath, name, commit, Options.BpmFileName))
+    if err == nil {
+        return semver.Make(moduleBpm.Version)
+    }
+    repos := []string{}
+    if cacheItem, exists := moduleCache.Items[name]; exists {
+        repos = append(repos, cacheItem.Path)
+    }
+    if Options.UseLocalPath != "" {
+        repos = append(repos, path.Join(Options.UseLocalPath, name))
+    }
+    for _, repo := range repos {
+        git := GitExec{Path: repo}
+        content, showErr := git.ShowFile(commit, Options.BpmFileName)
+        if showErr != nil {
+            err = showErr
+            continue;
+        }
+        err = moduleBpm.LoadDependencyData(commit + ":" + Options.BpmFileName, []byte(content))
+        if err == nil {
+            return semver.Make(moduleBpm.Version)
+        }
+    }
+    return semver.Version{}, err;
+}
@@ -0,0 +1,107 @@
+package main;
+
+import (
+    "os"
+    "testing"
+    "path/filepath"
+)
+
+func TestVersionBumpInferLevel(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useBpmCache(t, temp)()
+    defer useResolution(t, "update")()
+    for commit, version := range map[string]string{"1111111": "1.0.0", "2222222": "1.2.0", "3333333": "2.0.0"} {
+        writeFiles(t, filepath.Join(temp, "mortar", commit), map[string]string{"bpm.json": `{"name": "mortar", "version": "` + version + `", "dependencies": {}}`})
+    }
+    tests := []struct {
+        name string
+        previous string
+        commit string
+        expected string
+    }{
+        {name: "same major version", previous: "1111111", commit: "2222222", expected: "patch"},
+        {name: "major version", previous: "1111111", commit: "3333333", expected: "minor"},
+        {name: "older major version", previous: "3333333", commit: "1111111", expected: "patch"},
+        {name: "unknown version", previous: "1111111", commit: "9999999", expected: "patch"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            bpm := &BpmData{Name: "app", Version: "1.0.0", Dependencies: map[string]*BpmDependency{"mortar": {Url: "../mortar.git", Commit: test.previous}}}
+            bump := NewVersionBump(bpm)
+            bpm.Dependencies["mortar"].Commit = test.commit
+            level := bump.InferLevel(bpm)
+            if level != test.expected {
+                t.Errorf("Expected the level %s but got %s", test.expected, level)
+            }
+        })
+    }
+}
+
+// The version of a commit that is not in the bpm cache is read from the git history of the cached module
+func TestVersionBumpInferLevelGit(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useBpmCache(t, filepath.Join(temp, "bpm_modules"))()
+    defer useResolution(t, "update")()
+    repo := filepath.Join(temp, "mortar")
+    first := commitFiles(t, repo, map[string]string{"bpm.json": `{"name": "mortar", "version": "1.0.0", "dependencies": {}}`})
+    second := commitFiles(t, repo, map[string]string{"bpm.json": `{"name": "mortar", "version": "2.0.0", "dependencies": {}}`})
+    moduleCache.Add(&ModuleCacheItem{Name: "mortar", Version: "2.0.0", Commit: second, Path: repo})
+    bpm := &BpmData{Name: "app", Version: "1.0.0", Dependencies: map[string]*BpmDependency{"mortar": {Url: "../mortar.git", Commit: first}}}
+    bump := NewVersionBump(bpm)
+    bpm.Dependencies["mortar"].Commit = second
+    if level := bump.InferLevel(bpm); level != "minor" {
+        t.Errorf("Expected the level minor but got %s", level)
+    }
+}
+
+func TestVersionBumpApply(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useBpmCache(t, temp)()
+    defer useResolution(t, "update")()
+    writeFiles(t, temp, map[string]string{
+        "mortar/1111111/bpm.json": `{"name": "mortar", "version": "1.0.0", "dependencies": {}}`,
+        "mortar/3333333/bpm.json": `{"name": "mortar", "version": "2.0.0", "dependencies": {}}`,
+    })
+    tests := []struct {
+        name string
+        bump string
+        change func(bpm *BpmData)
+        changed bool
+        expected string
+    }{
+        {name: "no change", bump: "patch", change: func(bpm *BpmData) {}, expected: "1.2.3"},
+        {name: "same commit written again", bump: "major", change: func(bpm *BpmData) {
+            bpm.Dependencies["mortar"] = &BpmDependency{Url: "../mortar.git", Commit: "1111111"}
+        }, expected: "1.2.3"},
+        {name: "patch", bump: "patch", change: func(bpm *BpmData) { bpm.Dependencies["mortar"].Commit = "3333333" }, changed: true, expected: "1.2.4"},
+        {name: "minor", bump: "minor", change: func(bpm *BpmData) { bpm.Dependencies["mortar"].Commit = "3333333" }, changed: true, expected: "1.3.0"},
+        {name: "major", bump: "major", change: func(bpm *BpmData) { bpm.Dependencies["mortar"].Commit = "3333333" }, changed: true, expected: "2.0.0"},
+        {name: "none", bump: "none", change: func(bpm *BpmData) { bpm.Dependencies["mortar"].Commit = "3333333" }, changed: true, expected: "1.2.3"},
+        {name: "auto", bump: "auto", change: func(bpm *BpmData) { bpm.Dependencies["mortar"].Commit = "3333333" }, changed: true, expected: "1.3.0"},
+        {name: "new dependency", bump: "auto", change: func(bpm *BpmData) {
+            bpm.Dependencies["login-client"] = &BpmDependency{Url: "../login-client.git", Commit: "4444444"}
+        }, changed: true, expected: "1.2.4"},
+        {name: "removed dependency", bump: "patch", change: func(bpm *BpmData) { delete(bpm.Dependencies, "mortar") }, changed: true, expected: "1.2.4"},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            Options.Bump = test.bump
+            bpm := &BpmData{Name: "app", Version: "1.2.3", Dependencies: map[string]*BpmDependency{"mortar": {Url: "../mortar.git", Commit: "1111111"}}}
+            bump := NewVersionBump(bpm)
+            test.change(bpm)
+            changed, err := bump.Apply(bpm)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if changed != test.changed {
+                t.Errorf("Expected changed to be %v but got %v", test.changed, changed)
+            }
+            if bpm.Version != test.expected {
+                t.Errorf("Expected the version %s but got %s", test.expected, bpm.Version)
+            }
+        })
+    }
+}
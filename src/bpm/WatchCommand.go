@@ -0,0 +1,279 @@
+package main;
+
+import (
+    "os"
+    "path"
+    "sort"
+    "time"
+    "io/ioutil"
+    "os/signal"
+    "bpmerror"
+)
+
+// Watches the source folders of the local modules and copies the changed files to the bpm cache and to node_modules
+type WatchCommand struct {
+    Bpm *BpmData
+    Modules []*WatchModule
+    notifier *FileNotifier
+}
+
+type WatchFile struct {
+    Size int64
+    ModTime time.Time
+    IsDir bool
+    IsLink bool
+}
+
+type WatchModule struct {
+    Name string
+    Source string
+    Cache string
+    Files map[string]WatchFile
+}
+
+func (cmd *WatchCommand) Name() string {
+    return "watch"
+}
+
+// Read the files in the source folder. The excluded and ignored files are skipped, the same as when copying.
+func (module *WatchModule) Scan() map[string]WatchFile {
+    files := make(map[string]WatchFile)
+    ignore, err := NewIgnoreList(Options.ExcludeFileList).WithFile(module.Source, Options.IgnoreFileName)
+    if err != nil {
+        Log.Warn("Warning:", err)
+    }
+    var scan func(folder string, relativePath string)
+    scan = func(folder string, relativePath string) {
+        entries, _ := ioutil.ReadDir(folder)
+        for _, entry := range entries {
+            entryPath := path.Join(relativePath, entry.Name())
+            if ignore.Match(entryPath, entry.IsDir()) {
+                continue;
+            }
+            files[entryPath] = WatchFile{Size: entry.Size(), ModTime: entry.ModTime(), IsDir: entry.IsDir(), IsLink: entry.Mode() & os.ModeSymlink != 0}
+            if entry.IsDir() {
+                scan(path.Join(folder, entry.Name()), entryPath)
+            }
+        }
+    }
+    scan(module.Source, "")
+    return files;
+}
+
+// The folders the source is copied to. The node_modules folder is only a target when it is a copy and not a link.
+func (module *WatchModule) Targets() []string {
+    targets := []string{module.Cache}
+    nodeModulesPath := path.Join(Options.WorkingDir, "node_modules", module.Name)
+    if PathExists(nodeModulesPath) && !IsLink(nodeModulesPath) {
+        targets = append(targets, nodeModulesPath)
+    }
+    return targets;
+}
+
+// Copy the files that changed since the last sync and delete the files that were removed from the source.
+// Returns the number of files that were copied and removed.
+func (module *WatchModule) Sync() (int, int, error) {
+    files := module.Scan()
+    changed := []string{}
+    removed := []string{}
+    for name, file := range files {
+        previous, exists := module.Files[name]
+        if !exists || previous.IsDir != file.IsDir || previous.IsLink != file.IsLink || (!file.IsDir && (previous.Size != file.Size || !previous.ModTime.Equal(file.ModTime))) {
+            changed = append(changed, name)
+        }
+    }
+    for name := range module.Files {
+        if _, exists := files[name]; !exists {
+            removed = append(removed, name)
+        }
+    }
+    // Parent folders sort before their files, so the folders are created first
+    sort.Strings(changed)
+    sort.Strings(removed)
+    copyDir := CopyDir{}
+    for _, target := range module.Targets() {
+        for _, name := range removed {
+            Log.Debug("Removing", path.Join(target, name))
+            os.RemoveAll(path.Join(target, name))
+        }
+        for _, name := range changed {
+            targetPath := path.Join(target, name)
+            // A file that replaced a folder or a folder that replaced a file
+            if previous, exists := module.Files[name]; exists && (previous.IsDir != files[name].IsDir || previous.IsLink != files[name].IsLink) {
+                os.RemoveAll(targetPath)
+            }
+            if files[name].IsDir {
+                os.MkdirAll(targetPath, 0777)
+                continue;
+            }
+            Log.Debug("Copying", path.Join(module.Source, name), "to", targetPath)
+            os.MkdirAll(path.Dir(targetPath), 0777)
+            var err error
+            if files[name].IsLink {
+                err = copyDir.CopyLink(path.Join(module.Source, name), targetPath)
+            } else {
+                err = copyDir.CopyFile(path.Join(module.Source, name), targetPath)
+            }
+            if err != nil {
+                return 0, 0, bpmerror.New(err, "Error: Failed to copy " + path.Join(module.Source, name) + " to " + targetPath)
+            }
+        }
+    }
+    module.Files = files
+    return len(changed), len(removed), nil;
+}
+
+// Resolve the dependencies with the --root option and find the local modules to watch
+func (cmd *WatchCommand) resolve() error {
+    err := Options.DoesBpmFileExist();
+    if err != nil {
+        return err;
+    }
+    cmd.Bpm = &BpmData{};
+    err = cmd.Bpm.LoadFile(Options.BpmFileName);
+    if err != nil {
+        return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: There was a problem loading the bpm.json file")
+    }
+    if !cmd.Bpm.HasDependencies() {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: There are no dependencies to watch")
+    }
+    Options.EnsureBpmCacheFolder();
+    dependencyOverrides.Load(cmd.Bpm)
+    Log.Info("Processing all dependencies for", cmd.Bpm.Name, "version", cmd.Bpm.Version);
+    err = ProcessDependencies(cmd.Bpm, "", nil)
+    if err != nil {
+        return err;
+    }
+    err = moduleCache.InstallAndRunScripts()
+    if err != nil {
+        return err;
+    }
+    for _, key := range dependencyGraph.SortedKeys() {
+        node := dependencyGraph.Nodes[key]
+        if !node.Local {
+            continue;
+        }
+        module := &WatchModule{Name: node.Name, Source: node.Source, Cache: node.Cache}
+        module.Files = module.Scan()
+        cmd.Modules = append(cmd.Modules, module)
+    }
+    if len(cmd.Modules) == 0 {
+        return bpmerror.NewKind(bpmerror.Usage, nil, "Error: None of the dependencies were found in " + Options.UseLocalPath)
+    }
+    return nil;
+}
+
+// Watch every folder of the modules. Returns false if a folder could not be watched and polling must be used.
+func (cmd *WatchCommand) watchFolders() bool {
+    if cmd.notifier == nil {
+        return false;
+    }
+    for _, module := range cmd.Modules {
+        folders := []string{module.Source}
+        for name, file := range module.Files {
+            if file.IsDir {
+                folders = append(folders, path.Join(module.Source, name))
+            }
+        }
+        for _, folder := range folders {
+            err := cmd.notifier.Add(folder)
+            if err != nil {
+                Log.Warn("Warning: Could not watch", folder + ".", "Polling the folders instead.", err)
+                cmd.notifier.Close()
+                cmd.notifier = nil
+                return false;
+            }
+        }
+    }
+    return true;
+}
+
+func (cmd *WatchCommand) sync() {
+    synced := false
+    for _, module := range cmd.Modules {
+        previousFolders := []string{}
+        for name, file := range module.Files {
+            if file.IsDir {
+                previousFolders = append(previousFolders, name)
+            }
+        }
+        changed, removed, err := module.Sync()
+        if err != nil {
+            Log.Error(err)
+            continue;
+        }
+        if cmd.notifier != nil {
+            for _, name := range previousFolders {
+                if _, exists := module.Files[name]; !exists {
+                    cmd.notifier.Remove(path.Join(module.Source, name))
+                }
+            }
+        }
+        if changed > 0 || removed > 0 {
+            synced = true
+            Log.Info("Synced", module.Name + ":", changed, "changed,", removed, "removed")
+        }
+    }
+    if synced && Options.WatchExec != "" {
+        script := ScriptExec{Path: Options.WorkingDir, Name: cmd.Bpm.Name, Version: cmd.Bpm.Version, Commit: Options.LocalModuleName}
+        err := script.Run("watch", map[string]string{"watch": Options.WatchExec})
+        if err != nil {
+            Log.Error(err)
+        }
+    }
+}
+
+func (cmd *WatchCommand) Execute() (error) {
+    interval, err := time.ParseDuration(Options.WatchInterval)
+    if err != nil || interval <= 0 {
+        return bpmerror.NewKind(bpmerror.Usage, err, "Error: The --interval= option must be a duration such as 500ms or 2s")
+    }
+    err = cmd.resolve()
+    if err != nil {
+        return err;
+    }
+    if !Options.WatchPoll {
+        cmd.notifier, err = NewFileNotifier()
+        if err != nil {
+            Log.Debug("File notifications are not available. Polling the folders instead.", err)
+        }
+    }
+    for _, module := range cmd.Modules {
+        Log.Info("Watching", module.Name, "in", module.Source)
+    }
+    if cmd.watchFolders() {
+        Log.Info("Waiting for changes. Press Ctrl+C to stop.")
+    } else {
+        Log.Info("Polling for changes every", interval.String() + ". Press Ctrl+C to stop.")
+    }
+    stop := make(chan os.Signal, 1)
+    signal.Notify(stop, os.Interrupt)
+    for {
+        var events <-chan bool
+        var poll <-chan time.Time
+        if cmd.notifier != nil {
+            events = cmd.notifier.Events()
+        } else {
+            poll = time.After(interval)
+        }
+        select {
+        case <-stop:
+            if cmd.notifier != nil {
+                cmd.notifier.Close()
+            }
+            Log.Info("Stopped watching")
+            return nil;
+        case <-events:
+            // Wait a moment so the changes that are saved together are synced together
+            time.Sleep(100 * time.Millisecond)
+            select {
+            case <-events:
+            default:
+            }
+            cmd.sync()
+            cmd.watchFolders()
+        case <-poll:
+            cmd.sync()
+        }
+    }
+}
@@ -1,9 +1,5 @@
 package main;
 
-import (
-    "fmt"
-)
-
 type YarnExec struct {
     Path string
     ModulesFolder string
@@ -16,7 +12,7 @@ func (yarn *YarnExec) ParseOptions(args []string) {
 }
 
 func (yarn *YarnExec) Install() error {
-    fmt.Println("Running yarn install in", yarn.Path)
+    Log.Debug("Running yarn install in", yarn.Path)
     yarnCommand := "yarn install"
     if yarn.ModulesFolder != "" {
         yarnCommand = yarnCommand + " --modules-folder " + yarn.ModulesFolder
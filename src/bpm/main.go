@@ -4,6 +4,7 @@ package main;
 import (
     "os"
     "fmt"
+    "bufio"
     "path"
     "net/url"
     "strings"
@@ -11,12 +12,17 @@ import (
     "bpmerror"
 )
 
+var Log = Logger{Level: LogLevelInfo, Format: "text"}
 var moduleCache = ModuleCache{Items:make(map[string]*ModuleCacheItem)};
 var Options = BpmOptions {
     BpmCachePath: "bpm_modules",
     BpmFileName: "bpm.json",
     LocalModuleName: "local",
+    InstallModuleName: "install",
     ExcludeFileList: ".git|.gitignore|.gitmodules|bpm_modules|node_modules",
+    ConfigFileName: ".bpmrc",
+    InstalledFileName: ".bpm-installed.json",
+    IgnoreFileName: ".bpmignore",
 }
 
 func SliceIndex(limit int, predicate func(i int) bool) int {
@@ -32,48 +38,127 @@ func PathExists(path string) (bool) {
     _, err := os.Stat(path)
     if err == nil { return true }
     if os.IsNotExist(err) { return false }
-    fmt.Println("Error:", err)
+    Log.Error("Error:", err)
     return true
 }
 
+// Ask the user to confirm. The --yes option answers yes without asking. When the input is not a terminal, for example
+// in a build, there is nobody to answer so the --yes option is required.
+func Confirm(question string) (bool, error) {
+    if Options.Yes {
+        return true, nil;
+    }
+    if !IsTerminal(os.Stdin) {
+        return false, bpmerror.NewKind(bpmerror.Usage, nil, "Error: The input is not a terminal so bpm cannot ask: " + question + " Use the --yes option to confirm.")
+    }
+    fmt.Print(question + " [y/N] ")
+    answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+    answer = strings.ToLower(strings.TrimSpace(answer))
+    return answer == "y" || answer == "yes", nil;
+}
+
+// Returns true if the path is a symbolic link. The target of the link does not need to exist.
+func IsLink(path string) (bool) {
+    info, err := os.Lstat(path)
+    return err == nil && info.Mode() & os.ModeSymlink != 0;
+}
+
+// Remove the link to a local source folder that was created with the --link option, so the commit is used again
+func RemoveModuleLink(name string) {
+    localPath := path.Join(Options.BpmCachePath, name, Options.LocalModuleName)
+    if IsLink(localPath) {
+        Log.Info("Removing the link", localPath, "to use the commit in the", Options.BpmFileName, "file")
+        os.Remove(localPath)
+    }
+}
+
 func ProcessLocalModule(source string) (*BpmData, *ModuleCacheItem, error) {
     moduleBpm, err := LoadBpmData(source)
     if err != nil {
         return nil, nil, err;
     }
     itemPath := path.Join(Options.BpmCachePath, moduleBpm.Name, Options.LocalModuleName);
-    // Clean out the destination directory and then copy the files from the source directory to the final location in the bpm cache.
-    os.RemoveAll(itemPath)
-    os.MkdirAll(itemPath, 0777)
-    copyDir := CopyDir{Exclude:Options.ExcludeFileList}
-    err = copyDir.Copy(source, itemPath);
-    if err != nil {
-        return nil, nil, bpmerror.New(err, "Error: There was an issue trying to copy the local folder to the bpm_cache for " + source)
+    if Options.Link {
+        // If the destination is a link, only the link is removed
+        os.RemoveAll(itemPath)
+        // Link the source folder so the changes in the source folder are used without another update
+        os.MkdirAll(path.Dir(itemPath), 0777)
+        absSource := source
+        if !path.IsAbs(absSource) {
+            absSource = path.Join(Options.WorkingDir, absSource)
+        }
+        err = os.Symlink(absSource, itemPath)
+        if err != nil {
+            return nil, nil, bpmerror.New(err, "Error: There was an issue trying to link the local folder to the bpm_cache for " + source)
+        }
+    } else {
+        // Sync the files from the source directory to the final location in the bpm cache. Only the changed files are copied.
+        if IsLink(itemPath) {
+            os.Remove(itemPath)
+        }
+        os.MkdirAll(itemPath, 0777)
+        copyDir := CopyDir{Exclude:Options.ExcludeFileList, IgnoreFile: Options.IgnoreFileName, Sync: true, Checksum: Options.Checksum}
+        err = copyDir.Copy(source, itemPath);
+        if err != nil {
+            return nil, nil, bpmerror.New(err, "Error: There was an issue trying to copy the local folder to the bpm_cache for " + source)
+        }
+        Log.Info("Synced", source, "to", itemPath + ":", copyDir.Summary())
     }
-    cacheItem := &ModuleCacheItem{Name:moduleBpm.Name, Path: itemPath}
+    cacheItem := &ModuleCacheItem{Name:moduleBpm.Name, Version: moduleBpm.Version, Path: itemPath, Scripts: moduleBpm.Scripts, Linked: Options.Link}
     return moduleBpm, cacheItem, nil
 }
 
-func ProcessRemoteModule(itemRemoteUrl string, moduleCommit string) (*BpmData, *ModuleCacheItem, error) {
+// Splits a url of the form ../mortar.git#develop into the url and the branch name
+func SplitUrlBranch(moduleUrl string) (string, string) {
+    index := strings.LastIndex(moduleUrl, "#")
+    if index == -1 {
+        return moduleUrl, "";
+    }
+    return moduleUrl[:index], moduleUrl[index + 1:];
+}
+
+// Fetch the remote module into the bpm cache. If the commit is empty, then the latest commit of the branch is used.
+// If the branch is also empty, then the default branch of the remote is used.
+// The mirrors are tried in order when the url cannot be fetched.
+func ProcessRemoteModule(itemRemoteUrl string, mirrors []string, moduleCommit string, moduleBranch string) (*BpmData, *ModuleCacheItem, error) {
     itemPathTemp := path.Join(Options.WorkingDir, Options.BpmCachePath, "xx_temp_xx", "xx_temp_xx")
     defer os.RemoveAll(path.Join(itemPathTemp, ".."))
     os.RemoveAll(path.Join(itemPathTemp));
     os.MkdirAll(itemPathTemp, 0777)
     git := GitExec{Path:itemPathTemp}
-    err := git.InitAndCheckout(itemRemoteUrl, moduleCommit)
+    urls := append([]string{itemRemoteUrl}, mirrors...)
+    checkout := moduleCommit
+    if moduleCommit == "" {
+        if moduleBranch == "" {
+            var err error;
+            // The default branch is read from the first url that answers
+            for _, branchUrl := range urls {
+                moduleBranch, err = git.GetDefaultBranch(RewriteUrl(branchUrl))
+                if err == nil {
+                    Log.Info("Using the default branch", moduleBranch, "for", RewriteUrl(branchUrl))
+                    break
+                }
+            }
+            if err != nil {
+                return nil, nil, bpmerror.NewKind(bpmerror.Network, err, "Error: There was an issue determining the default branch for " + itemRemoteUrl)
+            }
+        }
+        checkout = "origin/" + moduleBranch
+    }
+    _, err := FetchWithFallback(git, urls, checkout)
     if err != nil {
-        return nil, nil, bpmerror.New(err, "Error: There was an issue initializing the repository for dependency " + itemRemoteUrl + " Url: " + itemRemoteUrl + " Commit: " + moduleCommit)
+        return nil, nil, bpmerror.New(err, "Error: There was an issue initializing the repository for dependency " + itemRemoteUrl + " Url: " + strings.Join(urls, ", ") + " Commit: " + checkout)
     }
-    if moduleCommit == "master" {
-        moduleCommit, err = git.GetLatestCommit()
-        if err != nil {
-            return nil, nil, err;
-        }
+    // The commit may also be a tag or a branch, so the hash of the commit that was checked out is saved
+    moduleCommit, err = git.GetLatestCommit()
+    if err != nil {
+        return nil, nil, err;
     }
     moduleBpm, err := LoadBpmData(itemPathTemp)
     if err != nil {
         return nil, nil, err;
     }
+    RemoveModuleLink(moduleBpm.Name)
     itemPath := path.Join(Options.BpmCachePath, moduleBpm.Name, moduleCommit);
     // Clean out the destination directory and then copy the files from the temp directory to the final location in the bpm cache.
     os.RemoveAll(itemPath)
@@ -85,10 +170,10 @@ func ProcessRemoteModule(itemRemoteUrl string, moduleCommit string) (*BpmData, *
 
     moduleBpmVersion, err := semver.Make(moduleBpm.Version);
     if err != nil {
-        fmt.Println("Could not read the version");
+        Log.Error("Error: Could not read the version of", moduleBpm.Name);
         return nil, nil, err;
     }
-    cacheItem := &ModuleCacheItem{Name:moduleBpm.Name, Version: moduleBpmVersion.String(), Commit: moduleCommit, Path: itemPath}
+    cacheItem := &ModuleCacheItem{Name:moduleBpm.Name, Version: moduleBpmVersion.String(), Commit: moduleCommit, Path: itemPath, Scripts: moduleBpm.Scripts}
     return moduleBpm, cacheItem, nil;
 }
 
@@ -119,9 +204,9 @@ func MakeRemoteUrl(itemUrl string) (string, error) {
         }
         parsedUrl, err := url.Parse(remoteUrl)
         if err != nil {
-            return "", bpmerror.New(err, "Error: There was a problem parsing the remote url " + remoteUrl)
+            return "", bpmerror.NewKind(bpmerror.Usage, err, "Error: There was a problem parsing the remote url " + remoteUrl)
         }
-        adjustedUrl = parsedUrl.Scheme + "://" + path.Join(parsedUrl.Host, parsedUrl.Path, itemUrl)
+        adjustedUrl = JoinRemoteUrl(parsedUrl, itemUrl)
     }
     return adjustedUrl, nil;
 }
@@ -129,107 +214,217 @@ func MakeRemoteUrl(itemUrl string) (string, error) {
 type ItemProcessedEvent func(item *ItemProcessed) error;
 
 func ProcessDependencies(bpm *BpmData, parentUrl string, itemProcessedEvent ItemProcessedEvent) (error) {
+    // The first bpm processed is the root of the dependency path used to detect cycles
+    if dependencyPath.IsEmpty() {
+        dependencyPath.Push(bpm.Name, "")
+        defer dependencyPath.Pop()
+    }
+    // The dev dependencies are only installed for the root project
+    includeDev := dependencyPath.IsRoot() && !Options.Production
     // Always process the keys sorted by name so the installation is consistent
-    sortedKeys := bpm.GetSortedKeys();
+    sortedKeys := bpm.GetInstallKeys(includeDev);
     for _, itemName := range sortedKeys {
-        item := bpm.Dependencies[itemName]
-        fmt.Println("Validating dependency", itemName)
-        err := item.Validate();
+        if bpm.Dependencies[itemName].IsOptional() {
+            ProcessOptionalDependency(itemName, func() error {
+                return processDependency(bpm, itemName, parentUrl, itemProcessedEvent)
+            })
+            continue;
+        }
+        err := processDependency(bpm, itemName, parentUrl, itemProcessedEvent)
         if err != nil {
             return err;
         }
-        if Options.UseLocalPath != "" && strings.Index(item.Url, "http") == -1 {
-            moduleSourceUrl := path.Join(Options.UseLocalPath, itemName);
-            fmt.Println("Processing local dependency in", moduleSourceUrl)
-            moduleBpm, cacheItem, err := ProcessLocalModule(moduleSourceUrl)
+    }
+    return nil;
+}
+
+// A failed optional dependency is only a warning. The modules that were added to the module cache and the dependency
+// graph while it was processed are removed again, so the part of it that was resolved is not installed.
+func ProcessOptionalDependency(itemName string, process func() error) {
+    savedCache := moduleCache.Clone()
+    savedGraph := dependencyGraph.Clone()
+    err := process()
+    if err != nil {
+        Log.Warn("Warning: Skipping the optional dependency", itemName + ".", err)
+        moduleCache = savedCache
+        dependencyGraph = savedGraph
+    }
+}
+
+// Fetch one dependency of the bpm and process its dependencies
+func processDependency(bpm *BpmData, itemName string, parentUrl string, itemProcessedEvent ItemProcessedEvent) (error) {
+    item := bpm.Dependencies[itemName]
+    if excludedBy := dependencyPath.ExcludedBy(itemName); excludedBy != "" {
+        Log.Debug("Excluding", itemName, "required by", dependencyPath.Last(), "since it is excluded by", excludedBy)
+        dependencyGraph.AddExcluded(itemName, excludedBy)
+        return nil;
+    }
+    Log.Debug("Validating dependency", itemName)
+    err := item.Validate();
+    if err != nil {
+        return err;
+    }
+    // The events get the dependency as it is in the bpm.json, even when an override changed the commit
+    requestedItem := item
+    item, override := dependencyOverrides.Apply(itemName, &dependencyPath, item)
+    if override != "" && item.Commit != requestedItem.Commit {
+        Log.Info("The override", override, "uses", itemName, "@", item.Commit, "instead of", requestedItem.Commit, "required by", dependencyPath.Last())
+    }
+    skip, err := dependencyPath.CheckCycle(itemName, item.Commit)
+    if err != nil {
+        return err;
+    }
+    if skip {
+        return nil;
+    }
+    isLocal := Options.UseLocalPath != "" && strings.Index(item.Url, "http") == -1
+    nodeCommit := item.Commit
+    if isLocal {
+        nodeCommit = Options.LocalModuleName
+    }
+    // A module at the same commit is only processed once. Other parents are recorded and notified. A module that was
+    // processed below an exclusion is missing the excluded modules, and an override key with parents may use other commits
+    // below it for another path, so those modules are processed again.
+    excluding := dependencyPath.HasExcludes() || len(item.Exclude) > 0 || dependencyOverrides.HasParents()
+    if node, exists := dependencyGraph.Get(itemName, nodeCommit); exists && !node.Excluding {
+        Log.Debug("Module", dependencyGraph.Key(itemName, nodeCommit), "was already processed")
+        node.AddParent(dependencyPath.Last())
+        if itemProcessedEvent != nil {
+            err = itemProcessedEvent(&ItemProcessed{Bpm:bpm, Source: node.Source, Cache: node.Cache, Name: itemName, Item: requestedItem, Local: node.Local})
             if err != nil {
                 return err;
             }
-            moduleCache.Add(cacheItem)
-            err = ProcessDependencies(moduleBpm, "", itemProcessedEvent)
+        }
+        return nil;
+    }
+    if isLocal {
+        moduleSourceUrl := path.Join(Options.UseLocalPath, itemName);
+        Log.Info("Processing local dependency in", moduleSourceUrl)
+        moduleBpm, cacheItem, err := ProcessLocalModule(moduleSourceUrl)
+        if err != nil {
+            return err;
+        }
+        // The name in the bpm.json may be different than the dependency name
+        skip, err = dependencyPath.CheckCycle(moduleBpm.Name, Options.LocalModuleName)
+        if err != nil {
+            return err;
+        }
+        if skip {
+            return nil;
+        }
+        moduleCache.Add(cacheItem)
+        dependencyGraph.Add(&DependencyNode{Name: itemName, Commit: nodeCommit, Version: moduleBpm.Version, Url: item.Url, Source: moduleSourceUrl, Cache: cacheItem.Path, Local: true, Excluding: excluding, Parents: []string{dependencyPath.Last()}})
+        dependencyPath.PushExcluding(moduleBpm.Name, Options.LocalModuleName, item.Exclude)
+        err = ProcessDependencies(moduleBpm, "", itemProcessedEvent)
+        dependencyPath.Pop()
+        if err != nil {
+            return err;
+        }
+
+        if itemProcessedEvent != nil {
+            err = itemProcessedEvent(&ItemProcessed{Bpm:bpm, Source: moduleSourceUrl, Cache: cacheItem.Path, Name: itemName, Item: requestedItem, Local: true})
             if err != nil {
                 return err;
             }
+        }
 
-            if itemProcessedEvent != nil {
-                err = itemProcessedEvent(&ItemProcessed{Bpm:bpm, Source: moduleSourceUrl, Cache: cacheItem.Path, Name: itemName, Item: item, Local: true})
-                if err != nil {
-                    return err;
-                }
-            }
+    } else {
+        phase := Log.StartPhase(itemName, "resolve")
+        phase.Info("Processing dependency", itemName)
 
-        } else {
-            fmt.Println("Processing dependency", itemName)
-
-            itemPath := path.Join(Options.BpmCachePath, itemName)
-            os.Mkdir(itemPath, 0777)
-
-            itemRemoteUrl := item.Url;
-            itemClonePath := path.Join(Options.WorkingDir, itemPath, item.Commit)
-            localPath := path.Join(Options.BpmCachePath, itemName, Options.LocalModuleName)
-            if PathExists(localPath) {
-                fmt.Println("Found local folder in the bpm modules. Using this folder", localPath)
-                itemClonePath = localPath;
-            } else if !PathExists(itemClonePath) {
-                fmt.Println("Could not find module", itemName, "in the bpm cache. Cloning repository...")
-                if item.Commit == "local" {
-                    return bpmerror.New(nil, "Error: The commit hash is specified as 'local' for dependency " + itemName + ". Please finalize the commit hash for this dependency.")
-                }
-                var err error;
-                parentUrl, err = MakeRemoteUrl(parentUrl);
-                if err != nil {
-                    return err;
-                }
-                tempUrl, err := url.Parse(parentUrl)
-                if err != nil {
-                    return bpmerror.New(err, "Error: There is something wrong with the module url " + parentUrl)
-                }
-                // If the item URL is a relative URL, then make a full URL using the parent url as the root.
-                if strings.Index(item.Url, "http") != 0 {
-                    itemRemoteUrl = tempUrl.Scheme + "://" + path.Join(tempUrl.Host, tempUrl.Path, item.Url)
-                }
-                os.Mkdir(itemClonePath, 0777)
-                git := GitExec{Path: itemClonePath}
-                err = git.InitAndCheckout(itemRemoteUrl, item.Commit)
-                if err != nil {
-                    os.RemoveAll(itemClonePath)
-                    return bpmerror.New(nil, "Error: There was an issue initializing the repository for dependency " + itemName + " Url: " + itemRemoteUrl + " Commit: " + item.Commit)
-                }
-            } else {
-                fmt.Println("Module", itemName, "already exists in the bpm cache.")
-            }
-            // Recursively get dependencies in the current dependency
-            moduleBpm := &BpmData{};
-            moduleBpmFilePath := path.Join(itemClonePath, Options.BpmFileName)
-            err := moduleBpm.LoadFile(moduleBpmFilePath);
-            if err != nil {
-                return bpmerror.New(err, "Error: Could not load the bpm.json file for dependency " + itemName)
-            }
+        itemPath := path.Join(Options.BpmCachePath, itemName)
+        os.Mkdir(itemPath, 0777)
 
-            fmt.Println("Validating bpm.json for", itemName)
-            err = moduleBpm.Validate();
+        itemRemoteUrl := item.Url;
+        itemClonePath := path.Join(Options.WorkingDir, itemPath, item.Commit)
+        RemoveModuleLink(itemName)
+        localPath := path.Join(Options.BpmCachePath, itemName, Options.LocalModuleName)
+        if PathExists(localPath) {
+            phase.Info("Found local folder in the bpm modules. Using this folder", localPath)
+            itemClonePath = localPath;
+        } else if !PathExists(itemClonePath) && Options.FromVendor != "" {
+            phase.Info("Could not find module", itemName, "in the bpm cache. Copying it from the vendor folder...")
+            err := CopyFromVendor(itemName, item.Commit, itemClonePath)
             if err != nil {
                 return err;
             }
-            cacheItem := &ModuleCacheItem{Name:moduleBpm.Name, Version: moduleBpm.Version, Commit: item.Commit, Path: itemClonePath}
-            fmt.Println("Adding to cache", cacheItem.Name)
-            moduleCache.AddLatest(cacheItem)
-
-            fmt.Println("Processing all dependencies for", moduleBpm.Name, "version", moduleBpm.Version);
-            if Options.UseParentUrl {
-                err = ProcessDependencies(moduleBpm, itemRemoteUrl, nil)
-            } else {
-                err = ProcessDependencies(moduleBpm, "", nil)
+        } else if !PathExists(itemClonePath) {
+            phase.Info("Could not find module", itemName, "in the bpm cache. Cloning repository...")
+            if item.Commit == "local" {
+                return bpmerror.NewKind(bpmerror.LocalCommit, nil, "Error: The commit hash is specified as 'local' for dependency " + itemName + ". Please finalize the commit hash for this dependency.")
             }
+            var err error;
+            parentUrl, err = MakeRemoteUrl(parentUrl);
             if err != nil {
                 return err;
             }
-
-            if itemProcessedEvent != nil {
-                err = itemProcessedEvent(&ItemProcessed{Bpm:bpm, Source: itemClonePath, Cache: cacheItem.Path, Name: itemName, Item: item, Local: false})
-                if err != nil {
-                    return err;
+            tempUrl, err := url.Parse(parentUrl)
+            if err != nil {
+                return bpmerror.New(err, "Error: There is something wrong with the module url " + parentUrl)
+            }
+            // If the item URL is a relative URL, then make a full URL using the parent url as the root.
+            if strings.Index(item.Url, "http") != 0 {
+                itemRemoteUrl = JoinRemoteUrl(tempUrl, item.Url)
+            }
+            urls := []string{itemRemoteUrl}
+            for _, mirror := range item.Mirrors {
+                if strings.Index(mirror, "http") != 0 {
+                    mirror = JoinRemoteUrl(tempUrl, mirror)
                 }
+                urls = append(urls, mirror)
+            }
+            git := GitExec{Path: itemClonePath}
+            itemRemoteUrl, err = FetchWithFallback(git, urls, item.Commit)
+            if err != nil {
+                os.RemoveAll(itemClonePath)
+                return bpmerror.New(err, "Error: There was an issue initializing the repository for dependency " + itemName + " Url: " + strings.Join(urls, ", ") + " Commit: " + item.Commit)
+            }
+        } else {
+            phase.Debug("Module", itemName, "already exists in the bpm cache.")
+        }
+        // Recursively get dependencies in the current dependency
+        moduleBpm := &BpmData{};
+        moduleBpmFilePath := path.Join(itemClonePath, Options.BpmFileName)
+        err := moduleBpm.LoadDependencyFile(moduleBpmFilePath);
+        if err != nil {
+            return bpmerror.NewKind(bpmerror.ManifestInvalid, err, "Error: Could not load the bpm.json file for dependency " + itemName)
+        }
+
+        phase.Debug("Validating bpm.json for", itemName)
+        err = moduleBpm.Validate();
+        if err != nil {
+            return err;
+        }
+        // The name in the bpm.json may be different than the dependency name
+        skip, err = dependencyPath.CheckCycle(moduleBpm.Name, item.Commit)
+        if err != nil {
+            return err;
+        }
+        if skip {
+            return nil;
+        }
+        cacheItem := &ModuleCacheItem{Name:moduleBpm.Name, Version: moduleBpm.Version, Commit: item.Commit, Path: itemClonePath, Scripts: moduleBpm.Scripts, Override: override != ""}
+        phase.Debug("Adding to cache", cacheItem.Name)
+        moduleCache.AddLatest(cacheItem)
+        dependencyGraph.Add(&DependencyNode{Name: itemName, Commit: nodeCommit, Version: moduleBpm.Version, Url: item.Url, Source: itemClonePath, Cache: cacheItem.Path, Local: false, Override: override, Excluding: excluding, Parents: []string{dependencyPath.Last()}})
+
+        phase.Done()
+        Log.Debug("Processing all dependencies for", moduleBpm.Name, "version", moduleBpm.Version);
+        dependencyPath.PushExcluding(moduleBpm.Name, item.Commit, item.Exclude)
+        if Options.UseParentUrl {
+            err = ProcessDependencies(moduleBpm, itemRemoteUrl, nil)
+        } else {
+            err = ProcessDependencies(moduleBpm, "", nil)
+        }
+        dependencyPath.Pop()
+        if err != nil {
+            return err;
+        }
+
+        if itemProcessedEvent != nil {
+            err = itemProcessedEvent(&ItemProcessed{Bpm:bpm, Source: itemClonePath, Cache: cacheItem.Path, Name: itemName, Item: requestedItem, Local: false})
+            if err != nil {
+                return err;
             }
         }
     }
@@ -243,10 +438,10 @@ func main() {
         err = Options.Command.Execute();
     }
     if err != nil {
-        fmt.Println(err)
-        fmt.Println("Finished with errors")
-        os.Exit(1)
+        Log.Error(err)
+        Log.Error("Finished with errors")
+        os.Exit(bpmerror.ExitCode(err))
     }
-    fmt.Println("Finished")
+    Log.Info("Finished")
     os.Exit(0)
 }
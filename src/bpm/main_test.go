@@ -0,0 +1,120 @@
+package main;
+
+import (
+    "os"
+    "testing"
+    "path/filepath"
+)
+
+func TestSplitUrlBranch(t *testing.T) {
+    tests := []struct {
+        name string
+        moduleUrl string
+        url string
+        branch string
+    }{
+        {name: "no branch", moduleUrl: "../mortar.git", url: "../mortar.git"},
+        {name: "relative url", moduleUrl: "../mortar.git#develop", url: "../mortar.git", branch: "develop"},
+        {name: "full url", moduleUrl: "https://github.com/acme/mortar.git#release/2.x", url: "https://github.com/acme/mortar.git", branch: "release/2.x"},
+        {name: "last separator", moduleUrl: "../mor#tar.git#develop", url: "../mor#tar.git", branch: "develop"},
+        {name: "empty branch", moduleUrl: "../mortar.git#", url: "../mortar.git"},
+        {name: "empty", moduleUrl: ""},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            moduleUrl, branch := SplitUrlBranch(test.moduleUrl)
+            if moduleUrl != test.url || branch != test.branch {
+                t.Errorf("Expected %q and %q but got %q and %q", test.url, test.branch, moduleUrl, branch)
+            }
+        })
+    }
+}
+
+// Start the test with an empty module cache, dependency graph and dependency path, and restore them after the test
+func useResolution(t *testing.T, args ...string) func() {
+    cache, graph, dp, overrides, osArgs := moduleCache, dependencyGraph, dependencyPath, dependencyOverrides, os.Args
+    moduleCache = ModuleCache{Items: make(map[string]*ModuleCacheItem)}
+    dependencyGraph = DependencyGraph{Nodes: make(map[string]*DependencyNode), Excluded: make(map[string][]string)}
+    dependencyPath = DependencyPath{}
+    dependencyOverrides = DependencyOverrides{}
+    os.Args = append([]string{"bpm"}, args...)
+    return func() {
+        moduleCache, dependencyGraph, dependencyPath, dependencyOverrides, os.Args = cache, graph, dp, overrides, osArgs
+    };
+}
+
+func TestProcessDependenciesOptional(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    defer useProject(t, temp)()
+    defer useResolution(t, "install")()
+    Options.UseLocalPath = filepath.Join(temp, "local")
+    writeFiles(t, temp, map[string]string{
+        "bpm.json": `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a", "commit": "aaaaaaa"}}, "optionalDependencies": {"opt": {"url": "../opt", "commit": "bbbbbbb"}}}`,
+        "local/a/bpm.json": `{"name": "a", "version": "1.0.0", "dependencies": {}}`,
+        // The dependency of the optional module is missing, so the optional module fails after it was added
+        "local/opt/bpm.json": `{"name": "opt", "version": "1.0.0", "dependencies": {"shared": {"url": "../shared", "commit": "ccccccc"}, "zz-missing": {"url": "../zz-missing", "commit": "ddddddd"}}}`,
+        "local/shared/bpm.json": `{"name": "shared", "version": "1.0.0", "dependencies": {}}`,
+    })
+    bpm := loadManifest(t, temp)
+    err := ProcessDependencies(bpm, "", nil)
+    if err != nil {
+        t.Fatalf("Expected the optional dependency to only be a warning but got %v", err)
+    }
+    if _, exists := moduleCache.Items["a"]; !exists {
+        t.Errorf("Expected the module a to be in the module cache")
+    }
+    for _, name := range []string{"opt", "shared"} {
+        if _, exists := moduleCache.Items[name]; exists {
+            t.Errorf("Expected the module %s of the failed optional dependency to be removed from the module cache", name)
+        }
+        if dependencyGraph.HasModule(name) {
+            t.Errorf("Expected the module %s of the failed optional dependency to be removed from the dependency graph", name)
+        }
+    }
+}
+
+func TestProcessDependenciesPathOverride(t *testing.T) {
+    temp := makeTempDir(t)
+    defer os.RemoveAll(temp)
+    git := filepath.Join(temp, "git")
+    first := commitFiles(t, filepath.Join(git, "mortar"), map[string]string{"bpm.json": `{"name": "mortar", "version": "1.0.0", "dependencies": {}}`})
+    second := commitFiles(t, filepath.Join(git, "mortar"), map[string]string{"bpm.json": `{"name": "mortar", "version": "1.1.0", "dependencies": {}}`})
+    client := commitFiles(t, filepath.Join(git, "login-client"), map[string]string{"bpm.json": `{"name": "login-client", "version": "1.0.0", "dependencies": {"mortar": {"url": "../mortar", "commit": "` + first + `"}}}`})
+    parent := `"dependencies": {"login-client": {"url": "../login-client", "commit": "` + client + `"}}}`
+    a := commitFiles(t, filepath.Join(git, "a"), map[string]string{"bpm.json": `{"name": "a", "version": "1.0.0", ` + parent})
+    b := commitFiles(t, filepath.Join(git, "b"), map[string]string{"bpm.json": `{"name": "b", "version": "1.0.0", ` + parent})
+    project := filepath.Join(temp, "project")
+    // The module a reaches login-client first, so the override below b only applies if login-client is processed again
+    writeFiles(t, project, map[string]string{
+        "bpm.json": `{"name": "app", "version": "1.0.0", "dependencies": {"a": {"url": "../a", "commit": "` + a + `"}, "b": {"url": "../b", "commit": "` + b + `"}}, "overrides": {"b>login-client>mortar": {"commit": "` + second + `"}}}`,
+    })
+    defer useProject(t, project)()
+    defer useResolution(t, "install")()
+    Options.Config = &BpmConfig{}
+    Options.UseRemoteUrl = "file://" + filepath.Join(git, "project")
+    Options.Retries = "0"
+    bpm := loadManifest(t, project)
+    dependencyOverrides.Load(bpm)
+    err := ProcessDependencies(bpm, "", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        commit string
+        override string
+    }{
+        {commit: first},
+        {commit: second, override: "b>login-client>mortar"},
+    }
+    for _, test := range tests {
+        node, exists := dependencyGraph.Get("mortar", test.commit)
+        if !exists {
+            t.Errorf("Expected mortar@%s to be in the dependency graph", test.commit)
+            continue
+        }
+        if node.Override != test.override {
+            t.Errorf("Expected mortar@%s to have the override %q but got %q", test.commit, test.override, node.Override)
+        }
+    }
+}
@@ -1,21 +1,87 @@
 package bpmerror;
 
+import (
+    "errors"
+)
+
+// The kind of an error determines the exit code of bpm so scripts can tell the failures apart.
+type Kind int
+
+const (
+    General Kind = iota
+    Usage
+    ManifestInvalid
+    Network
+    CommitNotFound
+    Conflict
+    PackageManager
+    LocalCommit
+    // The repository is not in the state the command requires, for example it has uncommitted changes or the tag exists
+    Precondition
+)
+
+var kindNames = []string{"general", "usage", "manifest invalid", "network", "commit not found", "conflict", "package manager", "local commit", "precondition"}
+
+// The exit codes for each kind. 0 is success, so the general failure starts at 1.
+func (kind Kind) ExitCode() int {
+    return int(kind) + 1;
+}
+
+func (kind Kind) Error() string {
+    if int(kind) < len(kindNames) {
+        return kindNames[kind] + " error";
+    }
+    return "unknown error";
+}
+
 type BpmError struct {
     Message string
+    Kind Kind
+    Cause error
 }
 
+// Create an error with a message. The kind of the base error is kept, so wrapping an error does not lose its kind.
 func New(base error, message string) (error){
-    var myError BpmError;
-    if base != nil && message != "" {
-        myError = BpmError{Message: message + ". " + base.Error()}
-    } else if message != "" {
-        myError = BpmError{Message: message}
-    } else {
-        myError = BpmError{Message: base.Error()}
+    kind := General
+    var baseError *BpmError
+    if errors.As(base, &baseError) {
+        kind = baseError.Kind
     }
-    return &myError;
+    return NewKind(kind, base, message);
+}
+
+func NewKind(kind Kind, base error, message string) (error) {
+    if message == "" && base != nil {
+        message = base.Error()
+    }
+    return &BpmError{Message: message, Kind: kind, Cause: base};
+}
+
+// Returns the exit code for the error. Errors that were not created by bpm are general errors.
+func ExitCode(err error) int {
+    if err == nil {
+        return 0;
+    }
+    var bpmError *BpmError
+    if errors.As(err, &bpmError) {
+        return bpmError.Kind.ExitCode();
+    }
+    return General.ExitCode();
 }
 
 func (e *BpmError) Error() string {
+    if e.Cause != nil && e.Cause.Error() != e.Message {
+        return e.Message + ". " + e.Cause.Error();
+    }
     return e.Message;
 }
+
+func (e *BpmError) Unwrap() error {
+    return e.Cause;
+}
+
+// Allows errors.Is(err, bpmerror.Network) to check the kind of an error
+func (e *BpmError) Is(target error) bool {
+    kind, ok := target.(Kind)
+    return ok && e.Kind == kind;
+}
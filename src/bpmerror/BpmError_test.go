@@ -0,0 +1,80 @@
+package bpmerror;
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+)
+
+func TestExitCode(t *testing.T) {
+    tests := []struct {
+        name string
+        err error
+        expected int
+    }{
+        {name: "success", err: nil, expected: 0},
+        {name: "not a bpm error", err: errors.New("failed"), expected: 1},
+        {name: "general", err: New(nil, "failed"), expected: 1},
+        {name: "usage", err: NewKind(Usage, nil, "bad option"), expected: 2},
+        {name: "manifest invalid", err: NewKind(ManifestInvalid, nil, "bad bpm.json"), expected: 3},
+        {name: "network", err: NewKind(Network, nil, "fetch failed"), expected: 4},
+        {name: "commit not found", err: NewKind(CommitNotFound, nil, "no commit"), expected: 5},
+        {name: "conflict", err: NewKind(Conflict, nil, "cycle"), expected: 6},
+        {name: "package manager", err: NewKind(PackageManager, nil, "npm failed"), expected: 7},
+        {name: "local commit", err: NewKind(LocalCommit, nil, "local"), expected: 8},
+        {name: "precondition", err: NewKind(Precondition, nil, "uncommitted changes"), expected: 9},
+        {name: "wrapped with New", err: New(NewKind(Network, nil, "fetch failed"), "install failed"), expected: 4},
+        {name: "wrapped with fmt", err: fmt.Errorf("install failed: %w", NewKind(Network, nil, "fetch failed")), expected: 4},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            code := ExitCode(test.err)
+            if code != test.expected {
+                t.Errorf("Expected the exit code %d but got %d", test.expected, code)
+            }
+        })
+    }
+}
+
+func TestUnwrap(t *testing.T) {
+    cause := errors.New("connection refused")
+    err := NewKind(Network, cause, "Error: Could not fetch mortar")
+    if errors.Unwrap(err) != cause {
+        t.Errorf("Expected the cause to be unwrapped but got %v", errors.Unwrap(err))
+    }
+    if !errors.Is(New(err, "Error: Install failed"), cause) {
+        t.Errorf("Expected the cause to be found through the wrapped errors")
+    }
+    if err.Error() != "Error: Could not fetch mortar. connection refused" {
+        t.Errorf("Expected the message to contain the cause but got %q", err.Error())
+    }
+    if NewKind(Network, cause, "").Error() != "connection refused" {
+        t.Errorf("Expected the message of the cause to be used when there is no message")
+    }
+    if errors.Unwrap(NewKind(Usage, nil, "bad option")) != nil {
+        t.Errorf("Expected no cause for an error without a base error")
+    }
+}
+
+func TestIs(t *testing.T) {
+    tests := []struct {
+        name string
+        err error
+        kind Kind
+        expected bool
+    }{
+        {name: "same kind", err: NewKind(Network, nil, "fetch failed"), kind: Network, expected: true},
+        {name: "other kind", err: NewKind(Network, nil, "fetch failed"), kind: Conflict, expected: false},
+        {name: "kind kept by New", err: New(NewKind(Precondition, nil, "tag exists"), "release failed"), kind: Precondition, expected: true},
+        {name: "wrapped with fmt", err: fmt.Errorf("update failed: %w", NewKind(Precondition, nil, "uncommitted changes")), kind: Precondition, expected: true},
+        {name: "general", err: New(errors.New("failed"), ""), kind: General, expected: true},
+        {name: "not a bpm error", err: errors.New("failed"), kind: General, expected: false},
+    }
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if errors.Is(test.err, test.kind) != test.expected {
+                t.Errorf("Expected errors.Is to be %v for the kind %v", test.expected, test.kind)
+            }
+        })
+    }
+}